@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+// Moonraker responses are shared between concurrent probes of the same target
+// with the same State, e.g. from HA Prometheus replicas, so that each endpoint is
+// only requested once. Responses can optionally be cached for a short TTL to also
+// share them between probes that arrive shortly after each other.
+
+// cachedResponse is a cached Moonraker response. Probes only use it while it is
+// younger than their own CacheTTL, and it is evicted after the TTL of the probe
+// that stored it.
+type cachedResponse struct {
+	data    []byte
+	stored  time.Time
+	expires time.Time
+}
+
+// cacheKey identifies a Moonraker request, including the connection settings and
+// credentials, so that responses are only shared between identical requests.
+func (c Collector) cacheKey(method, urlPath string, body []byte) string {
+	login := ""
+	if c.config.Login != nil {
+		login = c.config.Login.Username + ":" + c.config.Login.Password
+	}
+	return fmt.Sprintf("%s|%s|%s|%s|%s|%v|%s|%s %s|%s",
+		c.target, c.config.BasePath, c.config.APIKey, login, c.config.BasicAuth, c.config.Headers, fmt.Sprint(c.config.TLS), method, urlPath, body)
+}
+
+// probeResponses shares the Moonraker responses between the modules of a probe,
+// e.g. /machine/proc_stats between process_stats and network_stats, so that each
+// endpoint is requested at most once per probe.
+type probeResponses struct {
+	mu        sync.Mutex
+	responses map[string]*probeResponse
+}
+
+type probeResponse struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+// get returns the response for the key, sending the request on first use.
+func (p *probeResponses) get(key string, request func() ([]byte, error)) ([]byte, error) {
+	p.mu.Lock()
+	if p.responses == nil {
+		p.responses = map[string]*probeResponse{}
+	}
+	response, ok := p.responses[key]
+	if !ok {
+		response = &probeResponse{}
+		p.responses[key] = response
+	}
+	p.mu.Unlock()
+
+	response.once.Do(func() {
+		response.data, response.err = request()
+	})
+	return response.data, response.err
+}
+
+// cachedRequest returns the Moonraker response already received by another
+// module of the probe, from the cache, or from a concurrent in-flight request
+// for the same endpoint, before sending a new request.
+func (c Collector) cachedRequest(ctx context.Context, next moonraker.RequestFunc, method, urlPath string, body []byte) ([]byte, error) {
+	key := c.cacheKey(method, urlPath, body)
+	if c.responses == nil {
+		return c.sharedRequest(ctx, next, key, method, urlPath, body)
+	}
+	return c.responses.get(key, func() ([]byte, error) {
+		return c.sharedRequest(ctx, next, key, method, urlPath, body)
+	})
+}
+
+// sharedRequest returns the Moonraker response from the cache, or from a
+// concurrent in-flight request for the same endpoint, before sending a new request.
+// The in-flight request is sent with the context of the first probe, so a probe
+// that waited on it sends the request again if it was canceled by the deadline of
+// the first probe.
+func (c Collector) sharedRequest(ctx context.Context, next moonraker.RequestFunc, key, method, urlPath string, body []byte) ([]byte, error) {
+	endpoint, _, _ := strings.Cut(urlPath, "?")
+
+	state := c.state
+	if c.config.CacheTTL > 0 {
+		state.responseCacheMu.Lock()
+		cached, ok := state.responseCache[key]
+		state.responseCacheMu.Unlock()
+		if ok && time.Since(cached.stored) < c.config.CacheTTL {
+			state.cacheHits.WithLabelValues(endpoint).Inc()
+			return cached.data, nil
+		}
+	}
+
+	leader := false
+	inflight := state.inflightRequests.DoChan(key, func() (interface{}, error) {
+		leader = true
+		state.cacheMisses.WithLabelValues(endpoint).Inc()
+		data, err := c.resilientRequest(ctx, next, method, urlPath, body)
+		if err == nil && c.config.CacheTTL > 0 {
+			state.storeResponse(key, data, c.config.CacheTTL)
+		}
+		return data, err
+	})
+
+	// stop waiting on a shared request once this probe runs out of time budget
+	select {
+	case result := <-inflight:
+		if !leader && result.Err != nil && ctx.Err() == nil &&
+			(errors.Is(result.Err, context.Canceled) || errors.Is(result.Err, context.DeadlineExceeded)) {
+			// the probe that sent the shared request ran out of time budget before
+			// this one, so the request is sent again with the context of this probe
+			c.logger.Debugf("Shared request %s for %s was canceled, requesting again", urlPath, c.target)
+			return c.sharedRequest(ctx, next, key, method, urlPath, body)
+		}
+		if !leader {
+			state.cacheHits.WithLabelValues(endpoint).Inc()
+		}
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.([]byte), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// storeResponse caches the response and evicts expired entries.
+func (s *State) storeResponse(key string, data []byte, ttl time.Duration) {
+	s.responseCacheMu.Lock()
+	defer s.responseCacheMu.Unlock()
+
+	now := time.Now()
+	for k, cached := range s.responseCache {
+		if now.After(cached.expires) {
+			delete(s.responseCache, k)
+		}
+	}
+	s.responseCache[key] = cachedResponse{data: data, stored: now, expires: now.Add(ttl)}
+}
@@ -13,10 +13,11 @@ package collector
 // we emit (encoding/json ignores the rest) and skipping units whose state == "None".
 
 import (
+	"fmt"
 	"strconv"
 
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
 )
 
 // CFS response structures
@@ -102,29 +103,58 @@ func parseCFSFloat(s string) (float64, bool) {
 // Fetch CFS data from Moonraker
 func (c Collector) fetchCFSData() (*CFSResponse, error) {
 	var response CFSResponse
-	if err := c.fetchFromMoonraker("/printer/objects/query?box&filament_rack", &response); err != nil {
+	objects := moonraker.Objects{"box": nil, "filament_rack": nil}
+	if err := c.Client().QueryPrinterObjects(c.ctx, objects, &response.Result.Status); err != nil {
 		return nil, err
 	}
 	return &response, nil
 }
 
-func (c Collector) collectCFS(ch chan<- prometheus.Metric) {
+// Metrics of the cfs module
+var (
+	cfsEnabledDesc                = newGauge("cfs", "klipper_cfs_enabled", "CFS enabled state")
+	cfsAutoRefillEnabledDesc      = newGauge("cfs", "klipper_cfs_auto_refill_enabled", "CFS auto-refill enabled")
+	cfsFilamentUseupDesc          = newGauge("cfs", "klipper_cfs_filament_useup", "CFS filament used up flag")
+	cfsStateInfoDesc              = newGauge("cfs", "klipper_cfs_state_info", "CFS connection state", "state")
+	cfsActiveUnitDesc             = newGauge("cfs", "klipper_cfs_active_unit", "CFS box.filament value (semantics unconfirmed: likely active unit number)")
+	cfsRackLoadedInfoDesc         = newGauge("cfs", "klipper_cfs_rack_loaded_info", "Filament currently loaded at the toolhead (always 1)", "material", "color")
+	cfsRackVelocityDesc           = newGauge("cfs", "klipper_cfs_rack_velocity", "Loaded filament velocity (units unclear, likely mm/min)")
+	cfsActiveSlotDesc             = newGauge("cfs", "klipper_cfs_active_slot", "Active slot index within the unit (A=0..D=3, -1 if none)", "unit")
+	cfsActiveSlotInfoDesc         = newGauge("cfs", "klipper_cfs_active_slot_info", "Active slot details (always 1)", "unit", "slot", "material", "color")
+	cfsUnitTemperatureCelsiusDesc = newGauge("cfs", "klipper_cfs_unit_temperature_celsius", "CFS unit temperature in celsius", "unit")
+	cfsUnitHumidityPercentDesc    = newGauge("cfs", "klipper_cfs_unit_humidity_percent", "CFS unit relative humidity percent (assumed %RH)", "unit")
+	cfsUnitStateInfoDesc          = newGauge("cfs", "klipper_cfs_unit_state_info", "CFS unit connection state (always 1)", "unit", "state")
+	cfsUnitInfoDesc               = newGauge("cfs", "klipper_cfs_unit_info", "CFS unit hardware information (always 1)", "unit", "version", "sn", "mode")
+	cfsSlotInfoDesc               = newGauge("cfs", "klipper_cfs_slot_info", "CFS slot details (always 1)", "unit", "slot", "material", "color", "vendor")
+	cfsSlotRemainingDesc          = newGauge("cfs", "klipper_cfs_slot_remaining", "CFS slot remaining filament (units unclear: percent or mm)", "unit", "slot")
+)
+
+func init() {
+	RegisterModule(builtinModule{
+		name:           "cfs",
+		defaultEnabled: false,
+		endpoints:      []string{"/printer/objects/query"},
+		detect:         func(t TargetInfo) bool { return t.HasObject("box") },
+		collect:        Collector.collectCFS,
+	})
+}
+
+func (c Collector) collectCFS(ch chan<- prometheus.Metric) error {
 	result, err := c.fetchCFSData()
 	if err != nil {
-		log.Errorf("Failed to fetch CFS data: %v", err)
-		return
+		return fmt.Errorf("failed to fetch CFS data: %w", err)
 	}
 
 	box := result.Result.Status.Box
 	rack := result.Result.Status.FilamentRack
 
 	// === Box-level metrics ===
-	c.emitGauge(ch, "klipper_cfs_enabled", "CFS enabled state", boolToFloat64(box.Enable == 1))
-	c.emitGauge(ch, "klipper_cfs_auto_refill_enabled", "CFS auto-refill enabled", boolToFloat64(box.AutoRefill == 1))
-	c.emitGauge(ch, "klipper_cfs_filament_useup", "CFS filament used up flag", boolToFloat64(box.FilamentUseup == 1))
-	emitStateInfoMetric(ch, "klipper_cfs_state_info", "CFS connection state", "state", box.State)
+	cfsEnabledDesc.emit(ch, boolToFloat64(box.Enable == 1))
+	cfsAutoRefillEnabledDesc.emit(ch, boolToFloat64(box.AutoRefill == 1))
+	cfsFilamentUseupDesc.emit(ch, boolToFloat64(box.FilamentUseup == 1))
+	cfsStateInfoDesc.emitInfo(ch, box.State)
 	// NOTE: box.filament semantics are unconfirmed (active unit number? loaded count?).
-	c.emitGauge(ch, "klipper_cfs_active_unit", "CFS box.filament value (semantics unconfirmed: likely active unit number)", float64(box.Filament))
+	cfsActiveUnitDesc.emit(ch, float64(box.Filament))
 
 	// === Per-unit and per-slot metrics (skip disconnected units) ===
 	units := []struct {
@@ -137,16 +167,6 @@ func (c Collector) collectCFS(ch chan<- prometheus.Metric) {
 		{"T4", box.T4},
 	}
 
-	unitLabels := []string{"unit"}
-	activeSlotDesc := prometheus.NewDesc("klipper_cfs_active_slot", "Active slot index within the unit (A=0..D=3, -1 if none)", unitLabels, nil)
-	activeSlotInfoDesc := prometheus.NewDesc("klipper_cfs_active_slot_info", "Active slot details (always 1)", []string{"unit", "slot", "material", "color"}, nil)
-	unitTempDesc := prometheus.NewDesc("klipper_cfs_unit_temperature_celsius", "CFS unit temperature in celsius", unitLabels, nil)
-	unitHumidityDesc := prometheus.NewDesc("klipper_cfs_unit_humidity_percent", "CFS unit relative humidity percent (assumed %RH)", unitLabels, nil)
-	unitStateDesc := prometheus.NewDesc("klipper_cfs_unit_state_info", "CFS unit connection state (always 1)", []string{"unit", "state"}, nil)
-	unitInfoDesc := prometheus.NewDesc("klipper_cfs_unit_info", "CFS unit hardware information (always 1)", []string{"unit", "version", "sn", "mode"}, nil)
-	slotInfoDesc := prometheus.NewDesc("klipper_cfs_slot_info", "CFS slot details (always 1)", []string{"unit", "slot", "material", "color", "vendor"}, nil)
-	slotRemainingDesc := prometheus.NewDesc("klipper_cfs_slot_remaining", "CFS slot remaining filament (units unclear: percent or mm)", []string{"unit", "slot"}, nil)
-
 	slotLetters := []string{"A", "B", "C", "D"}
 
 	for _, u := range units {
@@ -155,19 +175,19 @@ func (c Collector) collectCFS(ch chan<- prometheus.Metric) {
 		}
 
 		// Unit state and hardware info
-		ch <- prometheus.MustNewConstMetric(unitStateDesc, prometheus.GaugeValue, 1, u.name, u.unit.State)
-		ch <- prometheus.MustNewConstMetric(unitInfoDesc, prometheus.GaugeValue, 1, u.name, u.unit.Version, u.unit.Sn, u.unit.Mode)
+		cfsUnitStateInfoDesc.emit(ch, 1, u.name, u.unit.State)
+		cfsUnitInfoDesc.emit(ch, 1, u.name, u.unit.Version, u.unit.Sn, u.unit.Mode)
 
 		if temp, ok := parseCFSFloat(u.unit.Temperature); ok {
-			ch <- prometheus.MustNewConstMetric(unitTempDesc, prometheus.GaugeValue, temp, u.name)
+			cfsUnitTemperatureCelsiusDesc.emit(ch, temp, u.name)
 		}
 		if humidity, ok := parseCFSFloat(u.unit.DryAndHumidity); ok {
-			ch <- prometheus.MustNewConstMetric(unitHumidityDesc, prometheus.GaugeValue, humidity, u.name)
+			cfsUnitHumidityPercentDesc.emit(ch, humidity, u.name)
 		}
 
 		// Active slot (the deliverable)
 		idx := slotLetterToIndex(u.unit.Filament)
-		ch <- prometheus.MustNewConstMetric(activeSlotDesc, prometheus.GaugeValue, float64(idx), u.name)
+		cfsActiveSlotDesc.emit(ch, float64(idx), u.name)
 		if idx >= 0 {
 			material := ""
 			color := ""
@@ -177,7 +197,7 @@ func (c Collector) collectCFS(ch chan<- prometheus.Metric) {
 			if idx < len(u.unit.ColorValue) {
 				color = u.unit.ColorValue[idx]
 			}
-			ch <- prometheus.MustNewConstMetric(activeSlotInfoDesc, prometheus.GaugeValue, 1, u.name, u.unit.Filament, material, color)
+			cfsActiveSlotInfoDesc.emit(ch, 1, u.name, u.unit.Filament, material, color)
 		}
 
 		// Per-slot metrics
@@ -194,18 +214,18 @@ func (c Collector) collectCFS(ch chan<- prometheus.Metric) {
 			if i < len(u.unit.Vender) {
 				vendor = u.unit.Vender[i]
 			}
-			ch <- prometheus.MustNewConstMetric(slotInfoDesc, prometheus.GaugeValue, 1, u.name, letter, material, color, vendor)
+			cfsSlotInfoDesc.emit(ch, 1, u.name, letter, material, color, vendor)
 
 			if i < len(u.unit.RemainLen) {
 				if remain, ok := parseCFSFloat(u.unit.RemainLen[i]); ok {
-					ch <- prometheus.MustNewConstMetric(slotRemainingDesc, prometheus.GaugeValue, remain, u.name, letter)
+					cfsSlotRemainingDesc.emit(ch, remain, u.name, letter)
 				}
 			}
 		}
 	}
 
 	// === Filament rack (loaded at toolhead) ===
-	emitStateInfoMetric2(ch, "klipper_cfs_rack_loaded_info", "Filament currently loaded at the toolhead (always 1)",
-		"material", rack.RemainMaterialType, "color", rack.RemainMaterialColor)
-	c.emitGauge(ch, "klipper_cfs_rack_velocity", "Loaded filament velocity (units unclear, likely mm/min)", rack.RemainMaterialVelocity)
+	cfsRackLoadedInfoDesc.emitInfo(ch, rack.RemainMaterialType, rack.RemainMaterialColor)
+	cfsRackVelocityDesc.emit(ch, rack.RemainMaterialVelocity)
+	return nil
 }
@@ -1,261 +1,357 @@
 package collector
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/exp/slices"
 )
 
+// Collector collects the metrics of the enabled modules from a single Moonraker
+// target.
 type Collector struct {
-	ctx     context.Context
-	target  string
-	modules []string
-	apiKey  string
+	ctx         context.Context
+	target      string
+	modules     []string
+	config      ClientConfig
+	httpClient  *http.Client
+	logger      log.FieldLogger
+	constLabels prometheus.Labels
+	labelName   func(string) string
+	filter      MetricFilter
+	timeout     time.Duration
+	state       *State
+	status      *probeStatus
+	client      *moonraker.Client
+	// responses is set for the duration of a Collect call
+	responses *probeResponses
 }
 
-func New(ctx context.Context, target string, modules []string, apiKey string) *Collector {
-	return &Collector{ctx: ctx, target: target, modules: modules, apiKey: apiKey}
+// New returns a collector for the Moonraker target, given as `host:port`, an
+// `http://` or `https://` URL, or `unix://` with the path of the Moonraker unix
+// socket.
+func New(target string, options ...Option) *Collector {
+	c := &Collector{
+		ctx:       context.Background(),
+		target:    target,
+		logger:    discardLogger(),
+		labelName: GetValidLabelName,
+		status:    &probeStatus{},
+	}
+	for _, option := range options {
+		option(c)
+	}
+	if c.modules == nil {
+		c.modules = DefaultModules()
+	}
+	if c.state == nil {
+		c.state = NewState()
+	}
+	c.client = c.state.clientFor(target, c.config, c.httpClient, c.logger)
+	return c
 }
 
-// Describe implements Prometheus.Collector.
-func (c Collector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- prometheus.NewDesc("dummy", "dummy", nil, nil)
+// discardLogger returns a logger that discards the log entries, used unless a
+// logger is set with WithLogger.
+func discardLogger() log.FieldLogger {
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	return logger
 }
 
-// Regex to match all invalid characters
-var prometheusMetricNameInvalidCharactersRegex = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
-
-// GetValidLabelName sanitizes a string to be used as a Prometheus label name
-// It converts hyphens to underscores and removes all other invalid characters
-func GetValidLabelName(str string) string {
-	// convert hyphens to underscores and strip out all other invalid characters
-	return prometheusMetricNameInvalidCharactersRegex.ReplaceAllString(strings.Replace(str, "-", "_", -1), "")
+// Context returns the probe context, to pass to the Client methods.
+func (c Collector) Context() context.Context {
+	return c.ctx
 }
 
-// A boolean cannot be directly converted to a number
-func boolToFloat64(boolean bool) (value float64) {
-	if boolean {
-		value = 1
-	}
-	return value
+// Describe implements Prometheus.Collector.
+//
+// Returns the descriptors of every metric of the enabled modules, and the probe
+// outcome metrics.
+func (c Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.labeled().Describe(ch)
 }
 
-// emitGauge is a convenience helper for emitting an unlabeled Gauge metric.
-func (c Collector) emitGauge(ch chan<- prometheus.Metric, name, desc string, value float64) {
-	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(name, desc, nil, nil),
-		prometheus.GaugeValue,
-		value)
+// Collect implements Prometheus.Collector.
+//
+// Modules are collected concurrently by a bounded pool of workers. The metric
+// channel is safe for concurrent sends, and the registry sorts the gathered
+// metrics, so the output is the same as collecting each module in turn.
+func (c Collector) Collect(ch chan<- prometheus.Metric) {
+	c.labeled().Collect(ch)
 }
 
-// emitCounter is a convenience helper for emitting an unlabeled Counter metric.
-func (c Collector) emitCounter(ch chan<- prometheus.Metric, name, desc string, value float64) {
-	ch <- prometheus.MustNewConstMetric(
-		prometheus.NewDesc(name, desc, nil, nil),
-		prometheus.CounterValue,
-		value)
+// unlabeledCollector collects the metrics without the const labels.
+type unlabeledCollector struct {
+	c Collector
 }
 
-// emitStateInfoMetric conditionally emits an info-style metric (Gauge=1) with a
-// single label carrying the state value, only when the state is non-empty.
-func emitStateInfoMetric(ch chan<- prometheus.Metric, metricName, description, labelName, stateValue string) {
-	if stateValue != "" {
-		desc := prometheus.NewDesc(metricName, description, []string{labelName}, nil)
-		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1.0, stateValue)
-	}
+func (u unlabeledCollector) Describe(ch chan<- *prometheus.Desc) {
+	u.c.describe(ch)
 }
 
-// emitStateInfoMetric2 is the two-label variant of emitStateInfoMetric.
-// It only emits when stateValue is non-empty, and accepts two label name/value pairs.
-func emitStateInfoMetric2(ch chan<- prometheus.Metric, metricName, description, label1Name, label1Value, label2Name, label2Value string) {
-	if label1Value != "" && label2Value != "" {
-		desc := prometheus.NewDesc(metricName, description, []string{label1Name, label2Name}, nil)
-		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1.0, label1Value, label2Value)
-	}
+func (u unlabeledCollector) Collect(ch chan<- prometheus.Metric) {
+	u.c.collect(ch)
 }
 
-// fetchFromMoonrakerPost performs an HTTP POST with a JSON body to the Moonraker API,
-// JSON-unmarshals the response, and checks for a 200 status code.
-func (c Collector) fetchFromMoonrakerPost(urlPath string, body interface{}, response interface{}) error {
-	url := "http://" + c.target + urlPath
-	log.Debug("Collecting metrics from " + url)
-
-	jsonBody, err := json.Marshal(body)
-	if err != nil {
-		return fmt.Errorf("unable to marshal request body: %w", err)
-	}
-
-	client := &http.Client{}
-	req, err := http.NewRequest("POST", url, bytes.NewReader(jsonBody))
-	if err != nil {
-		return fmt.Errorf("unable to create HTTP request for %s: %w", url, err)
-	}
-	req.Header.Set("Content-Type", "application/json")
-	if c.apiKey != "" {
-		req.Header.Set("X-API-KEY", c.apiKey)
-	}
-
-	res, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("unable to complete HTTP request: %w", err)
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code for %s: %d %s", urlPath, res.StatusCode, res.Status)
+// labeled returns the collector with the const labels added to every metric.
+func (c Collector) labeled() prometheus.Collector {
+	if len(c.constLabels) == 0 {
+		return unlabeledCollector{c}
 	}
+	return prometheus.WrapCollectorWith(c.constLabels, unlabeledCollector{c})
+}
 
-	data, err := io.ReadAll(res.Body)
-	if err != nil {
-		return fmt.Errorf("unable to read response body: %w", err)
+func (c Collector) describe(ch chan<- *prometheus.Desc) {
+	for _, d := range metricDescs {
+		if d.module == exporterModule {
+			ch <- d.desc
+		}
 	}
-
-	if err := json.Unmarshal(data, response); err != nil {
-		return fmt.Errorf("unable to unmarshal response to %T: %w", response, err)
+	// `auto` can enable any of the candidate modules
+	modules := c.resolveModules(func() []string {
+		var candidates []string
+		for _, m := range Modules() {
+			if autoCandidate(m) {
+				candidates = append(candidates, m.Name())
+			}
+		}
+		return candidates
+	})
+	for _, m := range enabledModules(modules) {
+		m.Describe(ch)
 	}
-
-	return nil
 }
 
-// fetchFromMoonraker performs an HTTP GET to the Moonraker API, JSON-unmarshals the
-// response, and checks for a 200 status code.
-func (c Collector) fetchFromMoonraker(urlPath string, response interface{}) error {
-	url := "http://" + c.target + urlPath
-	log.Debug("Collecting metrics from " + url)
-
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return fmt.Errorf("unable to create HTTP request for %s: %w", url, err)
+// resolveModules returns the names of the modules to collect, with `auto`
+// replaced by the auto modules, and without the modules excluded with
+// `-module`. The default modules are used when only exclusions are set.
+func (c Collector) resolveModules(auto func() []string) []string {
+	var (
+		modules  []string
+		excluded []string
+		included bool
+	)
+	for _, name := range c.modules {
+		switch {
+		case strings.HasPrefix(name, "-"):
+			excluded = append(excluded, strings.TrimPrefix(name, "-"))
+		case name == AutoModules:
+			modules = append(modules, auto()...)
+			included = true
+		default:
+			modules = append(modules, name)
+			included = true
+		}
 	}
-	if c.apiKey != "" {
-		req.Header.Set("X-API-KEY", c.apiKey)
+	if !included {
+		modules = DefaultModules()
 	}
-
-	res, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("unable to complete HTTP request: %w", err)
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code for %s: %d %s", urlPath, res.StatusCode, res.Status)
+	var resolved []string
+	for _, name := range modules {
+		if !slices.Contains(excluded, name) {
+			resolved = append(resolved, name)
+		}
 	}
+	return resolved
+}
 
-	data, err := io.ReadAll(res.Body)
-	if err != nil {
-		return fmt.Errorf("unable to read response body: %w", err)
+// enabledModules returns the registered modules with the names.
+func enabledModules(names []string) []Module {
+	var enabled []Module
+	seen := map[string]bool{}
+	for _, name := range names {
+		if m, ok := LookupModule(name); ok && !seen[name] {
+			seen[name] = true
+			enabled = append(enabled, m)
+		}
 	}
+	return enabled
+}
 
-	if err := json.Unmarshal(data, response); err != nil {
-		return fmt.Errorf("unable to unmarshal response to %T: %w", response, err)
-	}
+// Regex to match all invalid characters
+var prometheusMetricNameInvalidCharactersRegex = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
 
-	return nil
+// GetValidLabelName sanitizes a string to be used as a Prometheus label name
+// It converts hyphens to underscores and removes all other invalid characters
+func GetValidLabelName(str string) string {
+	// convert hyphens to underscores and strip out all other invalid characters
+	return prometheusMetricNameInvalidCharactersRegex.ReplaceAllString(strings.Replace(str, "-", "_", -1), "")
 }
 
-// Collect implements Prometheus.Collector.
-func (c Collector) Collect(ch chan<- prometheus.Metric) {
-
-	// Process Stats (and Network Stats)
-	if slices.Contains(c.modules, "process_stats") || slices.Contains(c.modules, "network_stats") {
-		log.Infof("Collecting process_stats for %s", c.target)
-		c.collectProcessAndNetworkStats(ch)
+// A boolean cannot be directly converted to a number
+func boolToFloat64(boolean bool) (value float64) {
+	if boolean {
+		value = 1
 	}
+	return value
+}
 
-	// Directory Information
-	if slices.Contains(c.modules, "directory_info") {
-		log.Infof("Collecting directory_info for %s", c.target)
-		c.collectDirectoryInfo(ch)
+// budgetExhausted reports whether the probe context is already done, in which case
+// the module is skipped instead of being started against an expired deadline.
+func (c Collector) budgetExhausted(module string) bool {
+	if err := c.ctx.Err(); err != nil {
+		c.logger.Warnf("Skipping %s for %s, scrape timeout budget exhausted: %v", module, c.target, err)
+		return true
 	}
+	return false
+}
 
-	// Job Queue
-	if slices.Contains(c.modules, "job_queue") {
-		log.Infof("Collecting job_queue for %s", c.target)
-		c.collectJobQueue(ch)
-	}
+// maxConcurrentModules bounds the number of modules collected in parallel for a
+// single target so that low powered Klipper hosts are not flooded with requests.
+const maxConcurrentModules = 4
 
-	// Job History
-	if slices.Contains(c.modules, "history") {
-		log.Infof("Collecting history for %s", c.target)
-		c.collectHistory(ch)
-	}
+// moduleResult records the outcome of collecting a single module.
+type moduleResult struct {
+	module   string
+	duration time.Duration
+	err      error
+}
 
-	// Current Print from Job History
-	if slices.Contains(c.modules, "history") {
-		log.Infof("Collecting active print for %s", c.target)
-		c.collectActivePrint(ch)
+// runModule collects a single module, unless the probe has already run out of
+// time, and returns the outcome.
+func (c Collector) runModule(ch chan<- prometheus.Metric, m Module) moduleResult {
+	name := m.Name()
+	start := time.Now()
+	var err error
+	if c.budgetExhausted(name) {
+		err = fmt.Errorf("skipped, scrape timeout budget exhausted: %w", c.ctx.Err())
+	} else {
+		c.logger.Infof("Collecting %s for %s", name, c.target)
+		if err = m.Collect(c, ch); err != nil {
+			c.logger.Errorf("Failed to collect %s for %s: %v", name, c.target, err)
+		}
 	}
+	return moduleResult{module: name, duration: time.Since(start), err: err}
+}
 
-	// Server Info
-	if slices.Contains(c.modules, "server_info") {
-		log.Infof("Collecting server_info for %s", c.target)
-		c.collectServerInfo(ch)
+func (c Collector) collect(ch chan<- prometheus.Metric) {
+	start := time.Now()
+	if c.timeout > 0 {
+		ctx, cancel := context.WithTimeout(c.ctx, c.timeout)
+		defer cancel()
+		c.ctx = ctx
 	}
 
-	// System Info
-	if slices.Contains(c.modules, "system_info") {
-		log.Infof("Collecting system_info for %s", c.target)
-		c.collectSystemInfo(ch)
-	}
+	// series dropped by emit are counted instead of being sent to the registry,
+	// and series excluded by the metric filter are dropped
+	metrics := make(chan prometheus.Metric)
+	forwarded := make(chan struct{})
+	go func(out chan<- prometheus.Metric) {
+		defer close(forwarded)
+		for m := range metrics {
+			if dropped, ok := m.(droppedSeries); ok {
+				c.dropSeries(dropped)
+				continue
+			}
+			if !c.filter.keep(m) {
+				continue
+			}
+			out <- m
+		}
+	}(ch)
+	defer func() {
+		close(metrics)
+		<-forwarded
+	}()
+	ch = metrics
 
 	// Temperature Store
 	// (deprecated since v0.8.0, use `printer_objects` instead)
 	// (removed with warning in v0.14.0)
 	if slices.Contains(c.modules, "temperature") {
-		log.Errorf("Collecting `temperature` metrics for %s is no longer supported, use `printer_objects` instead", c.target)
+		c.logger.Errorf("Collecting `temperature` metrics for %s is no longer supported, use `printer_objects` instead", c.target)
 	}
-
-	// Printer Objects
-	if slices.Contains(c.modules, "printer_objects") {
-		log.Infof("Collecting printer_objects for %s", c.target)
-		c.collectPrinterObjects(ch)
+	for _, name := range c.modules {
+		if _, ok := LookupModule(strings.TrimPrefix(name, "-")); !ok && name != "temperature" && name != AutoModules {
+			c.logger.Warnf("Unknown module %s for %s", name, c.target)
+		}
 	}
 
-	// Query Endstops
-	if slices.Contains(c.modules, "query_endstops") {
-		log.Infof("Collecting query_endstops for %s", c.target)
-		c.collectQueryEndstops(ch)
-	}
+	// modules requesting the same endpoint share the response during the probe
+	c.responses = &probeResponses{}
 
-	// MMU (Multi-Material Unit) - Happy Hare - only if present
-	if slices.Contains(c.modules, "mmu") {
-		log.Infof("Collecting mmu for %s", c.target)
-		c.collectMMU(ch)
+	var enabled []Module
+	for _, m := range enabledModules(c.resolveModules(c.detectModules)) {
+		if c.filter.wantsModule(m) {
+			enabled = append(enabled, m)
+		} else {
+			c.logger.Debugf("Skipping %s for %s, all metrics are excluded", m.Name(), c.target)
+		}
 	}
-
-	// CFS (Creality Filament System) - native `box` object - only if present
-	if slices.Contains(c.modules, "cfs") {
-		log.Infof("Collecting cfs for %s", c.target)
-		c.collectCFS(ch)
+	queue := make(chan Module, len(enabled))
+	for _, m := range enabled {
+		queue <- m
 	}
+	close(queue)
+
+	var (
+		wg        sync.WaitGroup
+		resultsMu sync.Mutex
+		results   []moduleResult
+	)
+	for i := 0; i < min(maxConcurrentModules, len(enabled)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := range queue {
+				result := c.runModule(ch, m)
+				resultsMu.Lock()
+				results = append(results, result)
+				resultsMu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
 
-	// Power Devices
-	if slices.Contains(c.modules, "device_power") {
-		log.Infof("Collecting device_power for %s", c.target)
-		c.collectPowerDevices(ch)
+	c.emitModuleResults(ch, results)
+	emitModulesEnabled(ch, enabled)
+	c.recordStatus(start, results)
+}
+
+// Probe outcome metrics, reported on every probe
+var (
+	moduleSuccessDesc  = newGauge(exporterModule, "klipper_exporter_module_success", "Whether collection of the module succeeded (1) or failed (0).", "module")
+	moduleDurationDesc = newGauge(exporterModule, "klipper_exporter_module_duration_seconds", "Time taken to collect the module in seconds.", "module")
+	upDesc             = newGauge(exporterModule, "klipper_up", "Whether Moonraker responded successfully for at least one module (1) or not (0).")
+	moduleEnabledDesc  = newGauge(exporterModule, "klipper_exporter_module_enabled", "Whether the module is collected from the target (1) or not (0), e.g. as detected with modules=auto.", "module")
+)
+
+// emitModuleResults emits the per-module success and duration metrics, and the
+// overall klipper_up metric, in the style of the blackbox exporter probe metrics.
+func (c Collector) emitModuleResults(ch chan<- prometheus.Metric, results []moduleResult) {
+	up := false
+	for _, result := range results {
+		up = up || result.err == nil
+		moduleSuccessDesc.emit(ch, boolToFloat64(result.err == nil), result.module)
+		moduleDurationDesc.emit(ch, result.duration.Seconds(), result.module)
 	}
+	upDesc.emit(ch, boolToFloat64(up))
+}
 
-	// Spoolman
-	if slices.Contains(c.modules, "spoolman") {
-		log.Infof("Collecting spoolman for %s", c.target)
-		c.collectSpoolman(ch)
+// emitModulesEnabled emits klipper_exporter_module_enabled for every registered
+// module.
+func emitModulesEnabled(ch chan<- prometheus.Metric, enabled []Module) {
+	for _, m := range Modules() {
+		moduleEnabledDesc.emit(ch, boolToFloat64(slices.ContainsFunc(enabled, func(e Module) bool {
+			return e.Name() == m.Name()
+		})), m.Name())
 	}
 }
 
 // only return metric if current job status is in progress
-func (c Collector) checkConditionStatusPrint(result MoonrakerHistoryCurrentPrintResponse, value float64) float64 {
+func (c Collector) checkConditionStatusPrint(history *moonraker.HistoryList, value float64) float64 {
 	var valueToReturn float64 = 0
-	if len(result.Result.Jobs) >= 1 && result.Result.Jobs[0].Status == "in_progress" {
+	if len(history.Jobs) >= 1 && history.Jobs[0].Status == "in_progress" {
 		valueToReturn = value
 	}
 	return valueToReturn
@@ -0,0 +1,99 @@
+package collector
+
+import (
+	"time"
+)
+
+// With `modules=auto` the modules are detected from the Moonraker components and
+// Klipper printer objects of the target, so the same scrape job can be used for
+// printers with and without, e.g., an MMU or Spoolman. The detected modules are
+// cached per target, and detected again when Klippy wasn't ready. The cached
+// modules of a target are removed once it has not been probed for
+// stateIdleTimeout.
+
+// AutoModules is the module name that enables the modules detected on the target.
+const AutoModules = "auto"
+
+// moduleDetectionTTL is how long the modules detected on a target are cached.
+const moduleDetectionTTL = 5 * time.Minute
+
+type detectedModules struct {
+	modules  []string
+	expires  time.Time
+	lastUsed time.Time
+}
+
+// autoCandidate reports whether `auto` can enable the module.
+func autoCandidate(m Module) bool {
+	if b, ok := m.(builtinModule); ok {
+		return b.detect != nil || b.defaultEnabled
+	}
+	if _, ok := m.(Detector); ok {
+		return true
+	}
+	return m.DefaultEnabled()
+}
+
+// autoEnabled reports whether `auto` enables the module on the target.
+func autoEnabled(m Module, target TargetInfo) bool {
+	if d, ok := m.(Detector); ok {
+		return d.Detect(target)
+	}
+	return m.DefaultEnabled()
+}
+
+// detectModules returns the names of the modules enabled by `auto` for the
+// target, from the cache or detected from /server/info and /printer/objects/list.
+func (c Collector) detectModules() []string {
+	c.state.detectedMu.Lock()
+	cached, ok := c.state.detected[c.target]
+	if ok {
+		cached.lastUsed = time.Now()
+		c.state.detected[c.target] = cached
+	}
+	c.state.detectedMu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.modules
+	}
+
+	info, err := c.Client().ServerInfo(c.ctx)
+	if err != nil {
+		if ok {
+			c.logger.Warnf("Unable to detect modules for %s, using the previously detected modules: %v", c.target, err)
+			return cached.modules
+		}
+		c.logger.Warnf("Unable to detect modules for %s, using the default modules: %v", c.target, err)
+		return DefaultModules()
+	}
+	target := TargetInfo{Components: info.Components}
+	ready := info.KlippyState == "ready"
+	if ready {
+		if target.Objects, err = c.Client().PrinterObjectsList(c.ctx); err != nil {
+			c.logger.Warnf("Unable to list printer objects for %s: %v", c.target, err)
+			ready = false
+		}
+	}
+
+	var modules []string
+	for _, m := range Modules() {
+		if autoEnabled(m, target) {
+			modules = append(modules, m.Name())
+		}
+	}
+	if !ready {
+		// the printer object modules are detected again once Klippy is ready
+		c.logger.Infof("Detected modules %v for %s, Klippy is %s", modules, c.target, info.KlippyState)
+		return modules
+	}
+	c.logger.Infof("Detected modules %v for %s", modules, c.target)
+	now := time.Now()
+	c.state.detectedMu.Lock()
+	for t, cached := range c.state.detected {
+		if now.Sub(cached.lastUsed) > stateIdleTimeout {
+			delete(c.state.detected, t)
+		}
+	}
+	c.state.detected[c.target] = detectedModules{modules: modules, expires: now.Add(moduleDetectionTTL), lastUsed: now}
+	c.state.detectedMu.Unlock()
+	return modules
+}
@@ -4,90 +4,57 @@ package collector
 // https://moonraker.readthedocs.io/en/latest/external_api/devices/
 
 import (
-	"net/url"
-
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
 )
 
-// MoonrakerPowerDevicesResponse wraps /machine/device_power/devices
-type MoonrakerPowerDevicesResponse struct {
-	Result struct {
-		Devices []MoonrakerPowerDevice `json:"devices"`
-	} `json:"result"`
-}
-
-// MoonrakerPowerDevice represents a single power device
-type MoonrakerPowerDevice struct {
-	Device              string `json:"device"`
-	Status              string `json:"status"`
-	LockedWhilePrinting bool   `json:"locked_while_printing"`
-	Type                string `json:"type"`
-}
+// Metrics of the device_power module
+var (
+	powerDeviceInfoDesc      = newGauge("device_power", "klipper_power_device_info", "Power device information (always 1).", "device", "type")
+	powerDeviceStatusDesc    = newGauge("device_power", "klipper_power_device_status", "Power device on/off status (1=on, 0=off/error/init).", "device")
+	powerDeviceStateInfoDesc = newGauge("device_power", "klipper_power_device_state_info", "Power device state information (always 1).", "device", "state")
+)
 
-// MoonrakerPowerStatusResponse wraps /machine/device_power/status
-type MoonrakerPowerStatusResponse struct {
-	Result map[string]string `json:"result"`
+func init() {
+	RegisterModule(builtinModule{
+		name:           "device_power",
+		defaultEnabled: true,
+		endpoints:      []string{"/machine/device_power/devices", "/machine/device_power/status"},
+		detect:         func(t TargetInfo) bool { return t.HasComponent("power") },
+		collect:        Collector.collectPowerDevices,
+	})
 }
 
-func (c Collector) collectPowerDevices(ch chan<- prometheus.Metric) {
+func (c Collector) collectPowerDevices(ch chan<- prometheus.Metric) error {
 	// Fetch list of power devices
-	var devicesResult MoonrakerPowerDevicesResponse
-	if err := c.fetchFromMoonraker("/machine/device_power/devices", &devicesResult); err != nil {
-		log.Error(err)
-		return
+	devices, err := c.Client().PowerDevices(c.ctx)
+	if err != nil {
+		return err
 	}
 
 	// Emit klipper_power_device_info{device, type} = 1 for each device
-	deviceInfoLabels := []string{"device", "type"}
-	deviceInfoDesc := prometheus.NewDesc(
-		"klipper_power_device_info",
-		"Power device information (always 1).",
-		deviceInfoLabels, nil,
-	)
-	for _, d := range devicesResult.Result.Devices {
-		ch <- prometheus.MustNewConstMetric(deviceInfoDesc, prometheus.GaugeValue, 1, GetValidLabelName(d.Device), d.Type)
+	for _, d := range devices {
+		powerDeviceInfoDesc.emit(ch, 1, c.labelName(d.Device), d.Type)
 	}
 
-	// Build status URL with device names as query parameters
-	deviceNames := make([]string, 0, len(devicesResult.Result.Devices))
-	for _, d := range devicesResult.Result.Devices {
+	// Fetch device statuses
+	deviceNames := make([]string, 0, len(devices))
+	for _, d := range devices {
 		deviceNames = append(deviceNames, d.Device)
 	}
-	statusURL := "/machine/device_power/status"
-	if len(deviceNames) > 0 {
-		statusURL += "?" + url.Values{"device": deviceNames}.Encode()
+	statuses, err := c.Client().PowerDeviceStatus(c.ctx, deviceNames...)
+	if err != nil {
+		return err
 	}
 
-	// Fetch device statuses
-	var statusResult MoonrakerPowerStatusResponse
-	if err := c.fetchFromMoonraker(statusURL, &statusResult); err != nil {
-		log.Error(err)
-		return
-	}
-
-	// Emit klipper_power_device_status{device} (1=on, 0=off/error/init)
-	statusLabels := []string{"device"}
-	statusDesc := prometheus.NewDesc(
-		"klipper_power_device_status",
-		"Power device on/off status (1=on, 0=off/error/init).",
-		statusLabels, nil,
-	)
-
-	// Emit klipper_power_device_state_info{device, state} = 1
-	stateInfoLabels := []string{"device", "state"}
-	stateInfoDesc := prometheus.NewDesc(
-		"klipper_power_device_state_info",
-		"Power device state information (always 1).",
-		stateInfoLabels, nil,
-	)
-
-	for device, state := range statusResult.Result {
+	// Emit klipper_power_device_status{device} (1=on, 0=off/error/init) and
+	// klipper_power_device_state_info{device, state} = 1
+	for device, state := range statuses {
 		status := 0.0
 		if state == "on" {
 			status = 1.0
 		}
-		ch <- prometheus.MustNewConstMetric(statusDesc, prometheus.GaugeValue, status, GetValidLabelName(device))
-		ch <- prometheus.MustNewConstMetric(stateInfoDesc, prometheus.GaugeValue, 1, GetValidLabelName(device), state)
+		powerDeviceStatusDesc.emit(ch, status, c.labelName(device))
+		powerDeviceStateInfoDesc.emit(ch, 1, c.labelName(device), state)
 	}
+	return nil
 }
@@ -4,28 +4,33 @@ package collector
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
 )
 
-type MoonrakerDirecotryInfoQueryResponse struct {
-	Result struct {
-		DiskUsage struct {
-			Total int64 `json:"total"`
-			Used  int64 `json:"used"`
-			Free  int64 `json:"free"`
-		} `json:"disk_usage"`
-	} `json:"result"`
+// Metrics of the directory_info module
+var (
+	diskUsageTotalDesc     = newGauge("directory_info", "klipper_disk_usage_total", "Klipper total disk space.")
+	diskUsageUsedDesc      = newGauge("directory_info", "klipper_disk_usage_used", "Klipper used disk space.")
+	diskUsageAvailableDesc = newGauge("directory_info", "klipper_disk_usage_available", "Klipper available disk space.")
+)
+
+func init() {
+	RegisterModule(builtinModule{
+		name:           "directory_info",
+		defaultEnabled: false,
+		endpoints:      []string{"/server/files/directory"},
+		collect:        Collector.collectDirectoryInfo,
+	})
 }
 
 // collectDirectoryInfo
-func (c Collector) collectDirectoryInfo(ch chan<- prometheus.Metric) {
-	var result MoonrakerDirecotryInfoQueryResponse
-	if err := c.fetchFromMoonraker("/server/files/directory?path=gcodes&extended=false", &result); err != nil {
-		log.Error(err)
-		return
+func (c Collector) collectDirectoryInfo(ch chan<- prometheus.Metric) error {
+	directory, err := c.Client().Directory(c.ctx, "gcodes", false)
+	if err != nil {
+		return err
 	}
 
-	c.emitGauge(ch, "klipper_disk_usage_total", "Klipper total disk space.", float64(result.Result.DiskUsage.Total))
-	c.emitGauge(ch, "klipper_disk_usage_used", "Klipper used disk space.", float64(result.Result.DiskUsage.Used))
-	c.emitGauge(ch, "klipper_disk_usage_available", "Klipper available disk space.", float64(result.Result.DiskUsage.Free))
+	diskUsageTotalDesc.emit(ch, float64(directory.DiskUsage.Total))
+	diskUsageUsedDesc.emit(ch, float64(directory.DiskUsage.Used))
+	diskUsageAvailableDesc.emit(ch, float64(directory.DiskUsage.Free))
+	return nil
 }
@@ -0,0 +1,134 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// MetricFilter selects the metrics collected from the target. The patterns are
+// matched against the whole metric name, and against the label values of each
+// series with LabelValues, e.g. to exclude a sensor by name. The exporter probe
+// outcome metrics, such as klipper_up, are always collected.
+//
+// A module is not collected when all of its metrics are excluded by name, and
+// the printer_objects module doesn't query the printer objects whose metrics are
+// all excluded by name.
+type MetricFilter struct {
+	// Include matches the metrics to collect, all metrics when nil.
+	Include *regexp.Regexp
+	// Exclude matches the metrics not to collect.
+	Exclude *regexp.Regexp
+	// LabelValues matches the patterns against the label values as well as the
+	// metric name. A series is collected when the metric name or any label value
+	// matches Include, and none matches Exclude.
+	LabelValues bool
+}
+
+// ParseMetricFilter returns the filter for the include and exclude patterns. A
+// series matches when any of the patterns matches.
+func ParseMetricFilter(include, exclude []string, labelValues bool) (MetricFilter, error) {
+	filter := MetricFilter{LabelValues: labelValues}
+	var err error
+	if filter.Include, err = compilePatterns(include); err != nil {
+		return MetricFilter{}, fmt.Errorf("invalid include pattern: %w", err)
+	}
+	if filter.Exclude, err = compilePatterns(exclude); err != nil {
+		return MetricFilter{}, fmt.Errorf("invalid exclude pattern: %w", err)
+	}
+	return filter, nil
+}
+
+// compilePatterns returns a regexp matching the whole string against any of the
+// patterns, or nil when there are no patterns.
+func compilePatterns(patterns []string) (*regexp.Regexp, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	for _, pattern := range patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, err
+		}
+	}
+	return regexp.Compile("^(?:" + strings.Join(patterns, "|") + ")$")
+}
+
+// empty reports whether the filter collects every metric.
+func (f MetricFilter) empty() bool {
+	return f.Include == nil && f.Exclude == nil
+}
+
+// wantsMetric reports whether any series of the metric can be collected, before
+// the label values are known.
+func (f MetricFilter) wantsMetric(name string) bool {
+	if f.Exclude != nil && f.Exclude.MatchString(name) {
+		return false
+	}
+	return f.Include == nil || f.LabelValues || f.Include.MatchString(name)
+}
+
+// wants reports whether the series is collected.
+func (f MetricFilter) wants(name string, labelValues []string) bool {
+	if !f.wantsMetric(name) {
+		return false
+	}
+	included := f.Include == nil || f.Include.MatchString(name)
+	for _, value := range labelValues {
+		if f.Exclude != nil && f.Exclude.MatchString(value) {
+			return false
+		}
+		included = included || f.Include.MatchString(value)
+	}
+	return included
+}
+
+// keep reports whether the metric is sent to the registry.
+func (f MetricFilter) keep(m prometheus.Metric) bool {
+	if f.empty() {
+		return true
+	}
+	name := descName(m.Desc())
+	if d, ok := definitions[m.Desc()]; ok && d.module == exporterModule {
+		return true
+	}
+	if !f.LabelValues {
+		return f.wantsMetric(name)
+	}
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		// dropped and counted by Collect
+		return true
+	}
+	labelValues := make([]string, 0, len(pb.GetLabel()))
+	for _, label := range pb.GetLabel() {
+		labelValues = append(labelValues, label.GetValue())
+	}
+	return f.wants(name, labelValues)
+}
+
+// wantsModule reports whether any metric of the module can be collected. Modules
+// outside the collector package are always collected.
+func (f MetricFilter) wantsModule(m Module) bool {
+	if _, ok := m.(builtinModule); !ok || f.empty() {
+		return true
+	}
+	for _, d := range metricDescs {
+		if d.module == m.Name() && f.wantsMetric(d.name) {
+			return true
+		}
+	}
+	return false
+}
+
+// descName returns the fully qualified metric name of the descriptor.
+func descName(desc *prometheus.Desc) string {
+	if d, ok := definitions[desc]; ok {
+		return d.name
+	}
+	_, name, _ := strings.Cut(desc.String(), `fqName: "`)
+	name, _, _ = strings.Cut(name, `"`)
+	return name
+}
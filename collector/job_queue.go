@@ -4,27 +4,31 @@ package collector
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
 )
 
-type MoonrakerJobQueueResponse struct {
-	Result struct {
-		QueuedJobs []MoonrakerQueuedJob `json:"queued_jobs"`
-		QueueState string               `json:"queue_state"`
-	} `json:"result"`
-}
+// Metrics of the job_queue module
+var (
+	jobQueueLengthDesc    = newGauge("job_queue", "klipper_job_queue_length", "Klipper job queue length.")
+	jobQueueStateInfoDesc = newGauge("job_queue", "klipper_job_queue_state_info", "The current state of the job queue.", "state")
+)
 
-type MoonrakerQueuedJob struct {
-	TimeInQueue float64 `json:"time_in_queue"`
+func init() {
+	RegisterModule(builtinModule{
+		name:           "job_queue",
+		defaultEnabled: true,
+		endpoints:      []string{"/server/job_queue/status"},
+		detect:         func(t TargetInfo) bool { return t.HasComponent("job_queue") },
+		collect:        Collector.collectJobQueue,
+	})
 }
 
-func (c Collector) collectJobQueue(ch chan<- prometheus.Metric) {
-	var result MoonrakerJobQueueResponse
-	if err := c.fetchFromMoonraker("/server/job_queue/status", &result); err != nil {
-		log.Error(err)
-		return
+func (c Collector) collectJobQueue(ch chan<- prometheus.Metric) error {
+	queue, err := c.Client().JobQueueStatus(c.ctx)
+	if err != nil {
+		return err
 	}
 
-	c.emitGauge(ch, "klipper_job_queue_length", "Klipper job queue length.", float64(len(result.Result.QueuedJobs)))
-	emitStateInfoMetric(ch, "klipper_job_queue_state_info", "The current state of the job queue.", "state", result.Result.QueueState)
+	jobQueueLengthDesc.emit(ch, float64(len(queue.QueuedJobs)))
+	jobQueueStateInfoDesc.emitInfo(ch, queue.QueueState)
+	return nil
 }
@@ -0,0 +1,133 @@
+package collector
+
+import (
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Every metric is defined once, with the module that emits it, when the package
+// is initialized. Describe returns the descriptors of the enabled modules, and
+// the metric catalog is generated from the same definitions.
+
+// exporterModule owns the metrics reported on every probe regardless of the
+// enabled modules.
+const exporterModule = "exporter"
+
+// metricDesc is the definition of a metric emitted by a module.
+type metricDesc struct {
+	module    string
+	name      string
+	help      string
+	valueType prometheus.ValueType
+	labels    []string
+	desc      *prometheus.Desc
+}
+
+// metricDescs holds every metric definition.
+var metricDescs []*metricDesc
+
+// definitions maps the descriptor of every metric definition to the definition.
+var definitions = map[*prometheus.Desc]*metricDesc{}
+
+func newMetricDesc(module string, valueType prometheus.ValueType, name, help string, labels ...string) *metricDesc {
+	d := &metricDesc{
+		module:    module,
+		name:      name,
+		help:      help,
+		valueType: valueType,
+		labels:    labels,
+		desc:      prometheus.NewDesc(name, help, labels, nil),
+	}
+	metricDescs = append(metricDescs, d)
+	definitions[d.desc] = d
+	return d
+}
+
+// newGauge defines a gauge metric emitted by the module.
+func newGauge(module, name, help string, labels ...string) *metricDesc {
+	return newMetricDesc(module, prometheus.GaugeValue, name, help, labels...)
+}
+
+// newCounter defines a counter metric emitted by the module.
+func newCounter(module, name, help string, labels ...string) *metricDesc {
+	return newMetricDesc(module, prometheus.CounterValue, name, help, labels...)
+}
+
+// emit sends the metric with the value and label values. A series that can't be
+// created, e.g. for a label value that is not valid UTF-8, is dropped and counted
+// in klipper_exporter_emit_errors_total instead of failing the whole probe.
+func (d *metricDesc) emit(ch chan<- prometheus.Metric, value float64, labelValues ...string) {
+	m, err := prometheus.NewConstMetric(d.desc, d.valueType, value, labelValues...)
+	if err != nil {
+		ch <- droppedSeries{metricDesc: d, err: err}
+		return
+	}
+	ch <- m
+}
+
+// droppedSeries takes the place of a series that could not be created, so that
+// Collect can count it before it reaches the registry.
+type droppedSeries struct {
+	*metricDesc
+	err error
+}
+
+func (s droppedSeries) Desc() *prometheus.Desc {
+	return s.desc
+}
+
+func (s droppedSeries) Write(*dto.Metric) error {
+	return s.err
+}
+
+// dropSeries counts and logs a dropped series.
+func (c Collector) dropSeries(s droppedSeries) {
+	c.state.emitErrors.WithLabelValues(s.module, s.name).Inc()
+	c.logger.Warnf("Dropping %s series of the %s module: %v", s.name, s.module, s.err)
+}
+
+// emitInfo emits an info-style metric (Gauge=1) carrying the label values, only
+// when all label values are non-empty.
+func (d *metricDesc) emitInfo(ch chan<- prometheus.Metric, labelValues ...string) {
+	for _, value := range labelValues {
+		if value == "" {
+			return
+		}
+	}
+	d.emit(ch, 1, labelValues...)
+}
+
+// MetricInfo describes a metric in the metric catalog.
+type MetricInfo struct {
+	Name   string   `json:"name"`
+	Type   string   `json:"type"`
+	Help   string   `json:"help"`
+	Labels []string `json:"labels"`
+	Module string   `json:"module"`
+}
+
+// Metrics returns the catalog of every metric the collector can emit, sorted by
+// module and name.
+func Metrics() []MetricInfo {
+	catalog := make([]MetricInfo, 0, len(metricDescs))
+	for _, d := range metricDescs {
+		metricType := "gauge"
+		if d.valueType == prometheus.CounterValue {
+			metricType = "counter"
+		}
+		labels := d.labels
+		if labels == nil {
+			labels = []string{}
+		}
+		catalog = append(catalog, MetricInfo{Name: d.name, Type: metricType, Help: d.help, Labels: labels, Module: d.module})
+	}
+	sort.Slice(catalog, func(i, j int) bool {
+		if catalog[i].Module != catalog[j].Module {
+			return catalog[i].Module < catalog[j].Module
+		}
+		return catalog[i].Name < catalog[j].Name
+	})
+	return catalog
+}
@@ -11,7 +11,7 @@ import (
 	"strconv"
 
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
 )
 
 // MMU Response structures
@@ -163,7 +163,8 @@ type MMUSlicerToolMap struct {
 // Fetch MMU data from Moonraker
 func (c Collector) fetchMMUData() (*MMUResponse, error) {
 	var response MMUResponse
-	if err := c.fetchFromMoonraker("/printer/objects/query?mmu&mmu_encoder%20mmu_encoder&mmu_machine", &response); err != nil {
+	objects := moonraker.Objects{"mmu": nil, "mmu_encoder mmu_encoder": nil, "mmu_machine": nil}
+	if err := c.Client().QueryPrinterObjects(c.ctx, objects, &response.Result.Status); err != nil {
 		return nil, err
 	}
 	return &response, nil
@@ -172,35 +173,27 @@ func (c Collector) fetchMMUData() (*MMUResponse, error) {
 // Fetch MMU pre-gate sensors
 func (c Collector) fetchMMUPreGateSensors(numGates int) ([]bool, []bool, error) {
 	// Build query for all pre-gate sensors
-	query := ""
+	objects := moonraker.Objects{}
 	for i := 0; i < numGates; i++ {
-		if i > 0 {
-			query += "&"
-		}
-		query += fmt.Sprintf("filament_switch_sensor%%20mmu_pre_gate_%d", i)
+		objects[fmt.Sprintf("filament_switch_sensor mmu_pre_gate_%d", i)] = nil
 	}
-	urlPath := "/printer/objects/query?" + query
 
-	var result map[string]interface{}
-	if err := c.fetchFromMoonraker(urlPath, &result); err != nil {
+	var status map[string]interface{}
+	if err := c.Client().QueryPrinterObjects(c.ctx, objects, &status); err != nil {
 		return nil, nil, err
 	}
 
 	detected := make([]bool, numGates)
 	enabled := make([]bool, numGates)
 
-	if resultData, ok := result["result"].(map[string]interface{}); ok {
-		if status, ok := resultData["status"].(map[string]interface{}); ok {
-			for i := 0; i < numGates; i++ {
-				sensorKey := fmt.Sprintf("filament_switch_sensor mmu_pre_gate_%d", i)
-				if sensor, ok := status[sensorKey].(map[string]interface{}); ok {
-					if d, ok := sensor["filament_detected"].(bool); ok {
-						detected[i] = d
-					}
-					if e, ok := sensor["enabled"].(bool); ok {
-						enabled[i] = e
-					}
-				}
+	for i := 0; i < numGates; i++ {
+		sensorKey := fmt.Sprintf("filament_switch_sensor mmu_pre_gate_%d", i)
+		if sensor, ok := status[sensorKey].(map[string]interface{}); ok {
+			if d, ok := sensor["filament_detected"].(bool); ok {
+				detected[i] = d
+			}
+			if e, ok := sensor["enabled"].(bool); ok {
+				enabled[i] = e
 			}
 		}
 	}
@@ -208,114 +201,167 @@ func (c Collector) fetchMMUPreGateSensors(numGates int) ([]bool, []bool, error)
 	return detected, enabled, nil
 }
 
-func (c Collector) collectMMU(ch chan<- prometheus.Metric) {
+// Metrics of the mmu module
+var (
+	mmuEnabledDesc                   = newGauge("mmu", "klipper_mmu_enabled", "MMU enabled state")
+	mmuHomedDesc                     = newGauge("mmu", "klipper_mmu_homed", "MMU homed state")
+	mmuNumGatesDesc                  = newGauge("mmu", "klipper_mmu_num_gates", "Number of MMU gates")
+	mmuHasBypassDesc                 = newGauge("mmu", "klipper_mmu_has_bypass", "MMU has bypass gate")
+	mmuCurrentUnitDesc               = newGauge("mmu", "klipper_mmu_current_unit", "Current MMU unit")
+	mmuCurrentToolDesc               = newGauge("mmu", "klipper_mmu_current_tool", "Current tool (-1=unknown, -2=bypass)")
+	mmuCurrentGateDesc               = newGauge("mmu", "klipper_mmu_current_gate", "Current gate")
+	mmuPrintStateInfoDesc            = newGauge("mmu", "klipper_mmu_print_state_info", "MMU print state", "state")
+	mmuActionInfoDesc                = newGauge("mmu", "klipper_mmu_action_info", "MMU current action", "action")
+	mmuOperationInfoDesc             = newGauge("mmu", "klipper_mmu_operation_info", "MMU current operation", "operation")
+	mmuFilamentLoadedDesc            = newGauge("mmu", "klipper_mmu_filament_loaded", "Filament loaded state")
+	mmuFilamentPositionMmDesc        = newGauge("mmu", "klipper_mmu_filament_position_mm", "Filament position in mm")
+	mmuFilamentPosStateDesc          = newGauge("mmu", "klipper_mmu_filament_pos_state", "Filament position state machine value")
+	mmuFilamentDirectionDesc         = newGauge("mmu", "klipper_mmu_filament_direction", "Filament direction (1=load, -1=unload)")
+	mmuToolchangesTotalDesc          = newGauge("mmu", "klipper_mmu_toolchanges_total", "Total toolchanges in current print")
+	mmuLastToolDesc                  = newGauge("mmu", "klipper_mmu_last_tool", "Last tool used")
+	mmuNextToolDesc                  = newGauge("mmu", "klipper_mmu_next_tool", "Next tool during toolchange")
+	mmuToolchangePurgeVolumeMm3Desc  = newGauge("mmu", "klipper_mmu_toolchange_purge_volume_mm3", "Suggested purge volume in mm³")
+	mmuRunoutDesc                    = newGauge("mmu", "klipper_mmu_runout", "Runout detected")
+	mmuClogDetectionModeDesc         = newGauge("mmu", "klipper_mmu_clog_detection_mode", "Clog detection mode (0=off, 1=manual, 2=auto)")
+	mmuEndlessSpoolEnabledDesc       = newGauge("mmu", "klipper_mmu_endless_spool_enabled", "Endless spool enabled (0=off, 1=enabled, 2=pre-gate)")
+	mmuSyncDriveEnabledDesc          = newGauge("mmu", "klipper_mmu_sync_drive_enabled", "Gear stepper synced to extruder")
+	mmuSyncFeedbackStateInfoDesc     = newGauge("mmu", "klipper_mmu_sync_feedback_state_info", "Sync feedback state", "state")
+	mmuServoPositionInfoDesc         = newGauge("mmu", "klipper_mmu_servo_position_info", "Servo position", "position")
+	mmuBowdenProgressPercentDesc     = newGauge("mmu", "klipper_mmu_bowden_progress_percent", "Bowden move progress (-1 if not active)")
+	mmuEncoderPositionMmDesc         = newGauge("mmu", "klipper_mmu_encoder_position_mm", "Encoder position in mm")
+	mmuEncoderDetectionLengthMmDesc  = newGauge("mmu", "klipper_mmu_encoder_detection_length_mm", "Clog detection length in mm")
+	mmuEncoderHeadroomMmDesc         = newGauge("mmu", "klipper_mmu_encoder_headroom_mm", "Current clog detection headroom in mm")
+	mmuEncoderMinHeadroomMmDesc      = newGauge("mmu", "klipper_mmu_encoder_min_headroom_mm", "Minimum headroom recorded in mm")
+	mmuEncoderDesiredHeadroomMmDesc  = newGauge("mmu", "klipper_mmu_encoder_desired_headroom_mm", "Desired headroom in mm")
+	mmuEncoderFlowRatePercentDesc    = newGauge("mmu", "klipper_mmu_encoder_flow_rate_percent", "Encoder flow rate percent")
+	mmuEncoderEnabledDesc            = newGauge("mmu", "klipper_mmu_encoder_enabled", "Encoder enabled for clog detection")
+	mmuSlicerTotalToolchangesDesc    = newGauge("mmu", "klipper_mmu_slicer_total_toolchanges", "Total toolchanges expected from slicer")
+	mmuSlicerInitialToolDesc         = newGauge("mmu", "klipper_mmu_slicer_initial_tool", "Initial tool from slicer")
+	mmuNumUnitsDesc                  = newGauge("mmu", "klipper_mmu_num_units", "Number of MMU units")
+	mmuActiveFilamentTemperatureDesc = newGauge("mmu", "klipper_mmu_active_filament_temperature", "Active filament temperature")
+	mmuActiveFilamentSpoolIdDesc     = newGauge("mmu", "klipper_mmu_active_filament_spool_id", "Active filament Spoolman spool ID")
+	mmuGateStatusDesc                = newGauge("mmu", "klipper_mmu_gate_status", "Gate status (-1=unknown, 0=empty, 1=available, 2=buffered)", "gate")
+	mmuGateTemperatureDesc           = newGauge("mmu", "klipper_mmu_gate_temperature", "Gate filament temperature", "gate")
+	mmuGateSpeedOverridePercentDesc  = newGauge("mmu", "klipper_mmu_gate_speed_override_percent", "Gate speed override percent", "gate")
+	mmuGateTtgMapDesc                = newGauge("mmu", "klipper_mmu_gate_ttg_map", "Tool-to-gate mapping value", "gate")
+	mmuGateEndlessSpoolGroupDesc     = newGauge("mmu", "klipper_mmu_gate_endless_spool_group", "Endless spool group", "gate")
+	mmuGateSpoolIdDesc               = newGauge("mmu", "klipper_mmu_gate_spool_id", "Spoolman spool ID (-1 if not set)", "gate")
+	mmuGateInfoDesc                  = newGauge("mmu", "klipper_mmu_gate_info", "Gate information (always 1)", "gate", "material", "color", "filament_name")
+	mmuToolExtrusionMultiplierDesc   = newGauge("mmu", "klipper_mmu_tool_extrusion_multiplier", "Tool extrusion multiplier (M221)", "tool")
+	mmuToolSpeedMultiplierDesc       = newGauge("mmu", "klipper_mmu_tool_speed_multiplier", "Tool speed multiplier (M220)", "tool")
+	mmuPreGateSensorDetectedDesc     = newGauge("mmu", "klipper_mmu_pre_gate_sensor_detected", "Pre-gate sensor filament detected", "gate")
+	mmuPreGateSensorEnabledDesc      = newGauge("mmu", "klipper_mmu_pre_gate_sensor_enabled", "Pre-gate sensor enabled", "gate")
+	mmuMachineInfoDesc               = newGauge("mmu", "klipper_mmu_machine_info", "MMU machine information (always 1)", "name", "vendor", "version", "selector_type")
+	mmuActiveFilamentInfoDesc        = newGauge("mmu", "klipper_mmu_active_filament_info", "Active filament information (always 1)", "name", "material", "color")
+)
+
+func init() {
+	RegisterModule(builtinModule{
+		name:           "mmu",
+		defaultEnabled: false,
+		endpoints:      []string{"/printer/objects/query"},
+		detect:         func(t TargetInfo) bool { return t.HasObject("mmu") },
+		collect:        Collector.collectMMU,
+	})
+}
+
+func (c Collector) collectMMU(ch chan<- prometheus.Metric) error {
 	result, err := c.fetchMMUData()
 	if err != nil {
-		log.Errorf("Failed to fetch MMU data: %v", err)
-		return
+		return fmt.Errorf("failed to fetch MMU data: %w", err)
 	}
 
 	mmu := result.Result.Status.MMU
 	machine := result.Result.Status.MMUMachine
 
 	// === Basic State Metrics ===
-	c.emitGauge(ch, "klipper_mmu_enabled", "MMU enabled state", boolToFloat64(mmu.Enabled))
-	c.emitGauge(ch, "klipper_mmu_homed", "MMU homed state", boolToFloat64(mmu.IsHomed))
-	c.emitGauge(ch, "klipper_mmu_num_gates", "Number of MMU gates", float64(mmu.NumGates))
-	c.emitGauge(ch, "klipper_mmu_has_bypass", "MMU has bypass gate", boolToFloat64(mmu.HasBypass))
-	c.emitGauge(ch, "klipper_mmu_current_unit", "Current MMU unit", float64(mmu.Unit))
-	c.emitGauge(ch, "klipper_mmu_current_tool", "Current tool (-1=unknown, -2=bypass)", float64(mmu.Tool))
-	c.emitGauge(ch, "klipper_mmu_current_gate", "Current gate", float64(mmu.Gate))
+	mmuEnabledDesc.emit(ch, boolToFloat64(mmu.Enabled))
+	mmuHomedDesc.emit(ch, boolToFloat64(mmu.IsHomed))
+	mmuNumGatesDesc.emit(ch, float64(mmu.NumGates))
+	mmuHasBypassDesc.emit(ch, boolToFloat64(mmu.HasBypass))
+	mmuCurrentUnitDesc.emit(ch, float64(mmu.Unit))
+	mmuCurrentToolDesc.emit(ch, float64(mmu.Tool))
+	mmuCurrentGateDesc.emit(ch, float64(mmu.Gate))
 
 	// === Print State ===
-	emitStateInfoMetric(ch, "klipper_mmu_print_state_info", "MMU print state", "state", mmu.PrintState)
+	mmuPrintStateInfoDesc.emitInfo(ch, mmu.PrintState)
 
 	// === Action State ===
-	emitStateInfoMetric(ch, "klipper_mmu_action_info", "MMU current action", "action", mmu.Action)
+	mmuActionInfoDesc.emitInfo(ch, mmu.Action)
 
 	// === Operation State ===
-	emitStateInfoMetric(ch, "klipper_mmu_operation_info", "MMU current operation", "operation", mmu.Operation)
+	mmuOperationInfoDesc.emitInfo(ch, mmu.Operation)
 
 	// === Filament State ===
 	filamentLoaded := 0.0
 	if mmu.Filament == "Loaded" {
 		filamentLoaded = 1.0
 	}
-	c.emitGauge(ch, "klipper_mmu_filament_loaded", "Filament loaded state", filamentLoaded)
-	c.emitGauge(ch, "klipper_mmu_filament_position_mm", "Filament position in mm", mmu.FilamentPosition)
-	c.emitGauge(ch, "klipper_mmu_filament_pos_state", "Filament position state machine value", float64(mmu.FilamentPos))
-	c.emitGauge(ch, "klipper_mmu_filament_direction", "Filament direction (1=load, -1=unload)", float64(mmu.FilamentDirection))
+	mmuFilamentLoadedDesc.emit(ch, filamentLoaded)
+	mmuFilamentPositionMmDesc.emit(ch, mmu.FilamentPosition)
+	mmuFilamentPosStateDesc.emit(ch, float64(mmu.FilamentPos))
+	mmuFilamentDirectionDesc.emit(ch, float64(mmu.FilamentDirection))
 
 	// === Toolchange Metrics ===
-	c.emitGauge(ch, "klipper_mmu_toolchanges_total", "Total toolchanges in current print", float64(mmu.NumToolchanges))
-	c.emitGauge(ch, "klipper_mmu_last_tool", "Last tool used", float64(mmu.LastTool))
-	c.emitGauge(ch, "klipper_mmu_next_tool", "Next tool during toolchange", float64(mmu.NextTool))
-	c.emitGauge(ch, "klipper_mmu_toolchange_purge_volume_mm3", "Suggested purge volume in mm³", mmu.ToolchangePurgeVolume)
+	mmuToolchangesTotalDesc.emit(ch, float64(mmu.NumToolchanges))
+	mmuLastToolDesc.emit(ch, float64(mmu.LastTool))
+	mmuNextToolDesc.emit(ch, float64(mmu.NextTool))
+	mmuToolchangePurgeVolumeMm3Desc.emit(ch, mmu.ToolchangePurgeVolume)
 
 	// === Runout ===
-	c.emitGauge(ch, "klipper_mmu_runout", "Runout detected", boolToFloat64(mmu.Runout))
+	mmuRunoutDesc.emit(ch, boolToFloat64(mmu.Runout))
 
 	// === Detection Settings ===
-	c.emitGauge(ch, "klipper_mmu_clog_detection_mode", "Clog detection mode (0=off, 1=manual, 2=auto)", float64(mmu.ClogDetectionEnabled))
-	c.emitGauge(ch, "klipper_mmu_endless_spool_enabled", "Endless spool enabled (0=off, 1=enabled, 2=pre-gate)", float64(mmu.EndlessSpoolEnabled))
+	mmuClogDetectionModeDesc.emit(ch, float64(mmu.ClogDetectionEnabled))
+	mmuEndlessSpoolEnabledDesc.emit(ch, float64(mmu.EndlessSpoolEnabled))
 
 	// === Sync Drive ===
-	c.emitGauge(ch, "klipper_mmu_sync_drive_enabled", "Gear stepper synced to extruder", boolToFloat64(mmu.SyncDrive))
+	mmuSyncDriveEnabledDesc.emit(ch, boolToFloat64(mmu.SyncDrive))
 
 	// Sync feedback state
-	emitStateInfoMetric(ch, "klipper_mmu_sync_feedback_state_info", "Sync feedback state", "state", mmu.SyncFeedbackState)
+	mmuSyncFeedbackStateInfoDesc.emitInfo(ch, mmu.SyncFeedbackState)
 
 	// === Servo Position ===
-	emitStateInfoMetric(ch, "klipper_mmu_servo_position_info", "Servo position", "position", mmu.Servo)
+	mmuServoPositionInfoDesc.emitInfo(ch, mmu.Servo)
 
 	// === Bowden Progress ===
-	c.emitGauge(ch, "klipper_mmu_bowden_progress_percent", "Bowden move progress (-1 if not active)", float64(mmu.BowdenProgress))
+	mmuBowdenProgressPercentDesc.emit(ch, float64(mmu.BowdenProgress))
 
 	// === Encoder Metrics ===
 	encoder := mmu.Encoder
-	c.emitGauge(ch, "klipper_mmu_encoder_position_mm", "Encoder position in mm", encoder.EncoderPos)
-	c.emitGauge(ch, "klipper_mmu_encoder_detection_length_mm", "Clog detection length in mm", encoder.DetectionLength)
-	c.emitGauge(ch, "klipper_mmu_encoder_headroom_mm", "Current clog detection headroom in mm", encoder.Headroom)
-	c.emitGauge(ch, "klipper_mmu_encoder_min_headroom_mm", "Minimum headroom recorded in mm", encoder.MinHeadroom)
-	c.emitGauge(ch, "klipper_mmu_encoder_desired_headroom_mm", "Desired headroom in mm", encoder.DesiredHeadroom)
-	c.emitGauge(ch, "klipper_mmu_encoder_flow_rate_percent", "Encoder flow rate percent", float64(encoder.FlowRate))
-	c.emitGauge(ch, "klipper_mmu_encoder_enabled", "Encoder enabled for clog detection", boolToFloat64(encoder.Enabled))
+	mmuEncoderPositionMmDesc.emit(ch, encoder.EncoderPos)
+	mmuEncoderDetectionLengthMmDesc.emit(ch, encoder.DetectionLength)
+	mmuEncoderHeadroomMmDesc.emit(ch, encoder.Headroom)
+	mmuEncoderMinHeadroomMmDesc.emit(ch, encoder.MinHeadroom)
+	mmuEncoderDesiredHeadroomMmDesc.emit(ch, encoder.DesiredHeadroom)
+	mmuEncoderFlowRatePercentDesc.emit(ch, float64(encoder.FlowRate))
+	mmuEncoderEnabledDesc.emit(ch, boolToFloat64(encoder.Enabled))
 
 	// === Per-Gate Metrics ===
-	gateLabels := []string{"gate"}
-	gateStatusDesc := prometheus.NewDesc("klipper_mmu_gate_status", "Gate status (-1=unknown, 0=empty, 1=available, 2=buffered)", gateLabels, nil)
-	gateTemperatureDesc := prometheus.NewDesc("klipper_mmu_gate_temperature", "Gate filament temperature", gateLabels, nil)
-	gateSpeedOverrideDesc := prometheus.NewDesc("klipper_mmu_gate_speed_override_percent", "Gate speed override percent", gateLabels, nil)
-	gateTTGMapDesc := prometheus.NewDesc("klipper_mmu_gate_ttg_map", "Tool-to-gate mapping value", gateLabels, nil)
-	gateEndlessSpoolGroupDesc := prometheus.NewDesc("klipper_mmu_gate_endless_spool_group", "Endless spool group", gateLabels, nil)
-	gateSpoolIdDesc := prometheus.NewDesc("klipper_mmu_gate_spool_id", "Spoolman spool ID (-1 if not set)", gateLabels, nil)
-
 	for i := 0; i < mmu.NumGates; i++ {
 		gateStr := strconv.Itoa(i)
 
 		if i < len(mmu.GateStatus) {
-			ch <- prometheus.MustNewConstMetric(gateStatusDesc, prometheus.GaugeValue, float64(mmu.GateStatus[i]), gateStr)
+			mmuGateStatusDesc.emit(ch, float64(mmu.GateStatus[i]), gateStr)
 		}
 		if i < len(mmu.GateTemperature) {
-			ch <- prometheus.MustNewConstMetric(gateTemperatureDesc, prometheus.GaugeValue, float64(mmu.GateTemperature[i]), gateStr)
+			mmuGateTemperatureDesc.emit(ch, float64(mmu.GateTemperature[i]), gateStr)
 		}
 		if i < len(mmu.GateSpeedOverride) {
-			ch <- prometheus.MustNewConstMetric(gateSpeedOverrideDesc, prometheus.GaugeValue, float64(mmu.GateSpeedOverride[i]), gateStr)
+			mmuGateSpeedOverridePercentDesc.emit(ch, float64(mmu.GateSpeedOverride[i]), gateStr)
 		}
 		if i < len(mmu.TTGMap) {
-			ch <- prometheus.MustNewConstMetric(gateTTGMapDesc, prometheus.GaugeValue, float64(mmu.TTGMap[i]), gateStr)
+			mmuGateTtgMapDesc.emit(ch, float64(mmu.TTGMap[i]), gateStr)
 		}
 		if i < len(mmu.EndlessSpoolGroups) {
-			ch <- prometheus.MustNewConstMetric(gateEndlessSpoolGroupDesc, prometheus.GaugeValue, float64(mmu.EndlessSpoolGroups[i]), gateStr)
+			mmuGateEndlessSpoolGroupDesc.emit(ch, float64(mmu.EndlessSpoolGroups[i]), gateStr)
 		}
 		if i < len(mmu.GateSpoolId) {
-			ch <- prometheus.MustNewConstMetric(gateSpoolIdDesc, prometheus.GaugeValue, float64(mmu.GateSpoolId[i]), gateStr)
+			mmuGateSpoolIdDesc.emit(ch, float64(mmu.GateSpoolId[i]), gateStr)
 		}
 	}
 
 	// === Gate Info (with material/color labels) ===
-	gateInfoLabels := []string{"gate", "material", "color", "filament_name"}
-	gateInfoDesc := prometheus.NewDesc("klipper_mmu_gate_info", "Gate information (always 1)", gateInfoLabels, nil)
 	for i := 0; i < mmu.NumGates; i++ {
 		gateStr := strconv.Itoa(i)
 		material := ""
@@ -330,70 +376,48 @@ func (c Collector) collectMMU(ch chan<- prometheus.Metric) {
 		if i < len(mmu.GateFilamentName) {
 			name = mmu.GateFilamentName[i]
 		}
-		ch <- prometheus.MustNewConstMetric(gateInfoDesc, prometheus.GaugeValue, 1, gateStr, material, color, name)
+		mmuGateInfoDesc.emit(ch, 1, gateStr, material, color, name)
 	}
 
 	// === Tool Multipliers ===
-	toolLabels := []string{"tool"}
-	extrusionMultiplierDesc := prometheus.NewDesc("klipper_mmu_tool_extrusion_multiplier", "Tool extrusion multiplier (M221)", toolLabels, nil)
-	speedMultiplierDesc := prometheus.NewDesc("klipper_mmu_tool_speed_multiplier", "Tool speed multiplier (M220)", toolLabels, nil)
-
 	for i := 0; i < mmu.NumGates; i++ {
 		toolStr := strconv.Itoa(i)
 		if i < len(mmu.ToolExtrusionMultipliers) {
-			ch <- prometheus.MustNewConstMetric(extrusionMultiplierDesc, prometheus.GaugeValue, mmu.ToolExtrusionMultipliers[i], toolStr)
+			mmuToolExtrusionMultiplierDesc.emit(ch, mmu.ToolExtrusionMultipliers[i], toolStr)
 		}
 		if i < len(mmu.ToolSpeedMultipliers) {
-			ch <- prometheus.MustNewConstMetric(speedMultiplierDesc, prometheus.GaugeValue, mmu.ToolSpeedMultipliers[i], toolStr)
+			mmuToolSpeedMultiplierDesc.emit(ch, mmu.ToolSpeedMultipliers[i], toolStr)
 		}
 	}
 
 	// === Pre-Gate Sensors ===
 	detected, enabled, err := c.fetchMMUPreGateSensors(mmu.NumGates)
 	if err != nil {
-		log.Warnf("Failed to fetch pre-gate sensors: %v", err)
+		c.logger.Warnf("Failed to fetch pre-gate sensors: %v", err)
 	} else {
-		preGateDetectedDesc := prometheus.NewDesc("klipper_mmu_pre_gate_sensor_detected", "Pre-gate sensor filament detected", gateLabels, nil)
-		preGateEnabledDesc := prometheus.NewDesc("klipper_mmu_pre_gate_sensor_enabled", "Pre-gate sensor enabled", gateLabels, nil)
 
 		for i := 0; i < mmu.NumGates; i++ {
 			gateStr := strconv.Itoa(i)
-			ch <- prometheus.MustNewConstMetric(preGateDetectedDesc, prometheus.GaugeValue, boolToFloat64(detected[i]), gateStr)
-			ch <- prometheus.MustNewConstMetric(preGateEnabledDesc, prometheus.GaugeValue, boolToFloat64(enabled[i]), gateStr)
+			mmuPreGateSensorDetectedDesc.emit(ch, boolToFloat64(detected[i]), gateStr)
+			mmuPreGateSensorEnabledDesc.emit(ch, boolToFloat64(enabled[i]), gateStr)
 		}
 	}
 
 	// === Slicer Tool Map Info ===
-	c.emitGauge(ch, "klipper_mmu_slicer_total_toolchanges", "Total toolchanges expected from slicer", float64(mmu.SlicerToolMap.TotalToolchanges))
-	c.emitGauge(ch, "klipper_mmu_slicer_initial_tool", "Initial tool from slicer", float64(mmu.SlicerToolMap.InitialTool))
+	mmuSlicerTotalToolchangesDesc.emit(ch, float64(mmu.SlicerToolMap.TotalToolchanges))
+	mmuSlicerInitialToolDesc.emit(ch, float64(mmu.SlicerToolMap.InitialTool))
 
 	// === Machine Info ===
-	machineInfoLabels := []string{"name", "vendor", "version", "selector_type"}
-	machineInfoDesc := prometheus.NewDesc("klipper_mmu_machine_info", "MMU machine information (always 1)", machineInfoLabels, nil)
-	ch <- prometheus.MustNewConstMetric(
-		machineInfoDesc,
-		prometheus.GaugeValue,
-		1,
-		machine.Unit0.Name,
-		machine.Unit0.Vendor,
-		machine.Unit0.Version,
-		machine.Unit0.SelectorType)
-
-	c.emitGauge(ch, "klipper_mmu_num_units", "Number of MMU units", float64(machine.NumUnits))
+	mmuMachineInfoDesc.emit(ch, 1, machine.Unit0.Name, machine.Unit0.Vendor, machine.Unit0.Version, machine.Unit0.SelectorType)
+
+	mmuNumUnitsDesc.emit(ch, float64(machine.NumUnits))
 
 	// === Active Filament Info ===
 	if mmu.ActiveFilament.FilamentName != "" {
-		activeFilamentLabels := []string{"name", "material", "color"}
-		activeFilamentDesc := prometheus.NewDesc("klipper_mmu_active_filament_info", "Active filament information (always 1)", activeFilamentLabels, nil)
-		ch <- prometheus.MustNewConstMetric(
-			activeFilamentDesc,
-			prometheus.GaugeValue,
-			1,
-			mmu.ActiveFilament.FilamentName,
-			mmu.ActiveFilament.Material,
-			mmu.ActiveFilament.Color)
-
-		c.emitGauge(ch, "klipper_mmu_active_filament_temperature", "Active filament temperature", float64(mmu.ActiveFilament.Temperature))
-		c.emitGauge(ch, "klipper_mmu_active_filament_spool_id", "Active filament Spoolman spool ID", float64(mmu.ActiveFilament.SpoolId))
+		mmuActiveFilamentInfoDesc.emit(ch, 1, mmu.ActiveFilament.FilamentName, mmu.ActiveFilament.Material, mmu.ActiveFilament.Color)
+
+		mmuActiveFilamentTemperatureDesc.emit(ch, float64(mmu.ActiveFilament.Temperature))
+		mmuActiveFilamentSpoolIdDesc.emit(ch, float64(mmu.ActiveFilament.SpoolId))
 	}
+	return nil
 }
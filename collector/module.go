@@ -0,0 +1,164 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/exp/slices"
+)
+
+// Module collects the metrics of a Moonraker or Klipper feature, and is enabled
+// per probe by name with the `modules` parameter. The built-in modules register
+// themselves when the package is initialized. Code embedding the collector can
+// register its own modules, e.g. for in-house Klipper extras, with RegisterModule.
+type Module interface {
+	// Name returns the name used to enable the module.
+	Name() string
+	// DefaultEnabled reports whether the module is collected when a probe
+	// doesn't set the modules.
+	DefaultEnabled() bool
+	// Endpoints returns the Moonraker endpoints the module requests.
+	Endpoints() []string
+	// Describe sends the descriptor of every metric the module can collect.
+	Describe(ch chan<- *prometheus.Desc)
+	// Collect requests the module data from Moonraker with the collector, see
+	// Collector.Fetch, and sends the metrics. An error marks the module as
+	// failed in the probe outcome metrics, the metrics sent are still reported.
+	Collect(c Collector, ch chan<- prometheus.Metric) error
+}
+
+// Detector is implemented by modules that can tell from the Moonraker components
+// and Klipper printer objects whether a target supports them, for
+// `modules=auto`. Modules that don't implement Detector are enabled by `auto`
+// when DefaultEnabled.
+type Detector interface {
+	// Detect reports whether the module should be collected from the target.
+	Detect(target TargetInfo) bool
+}
+
+// TargetInfo is what module detection knows about a target.
+type TargetInfo struct {
+	// Components are the loaded Moonraker components, from /server/info.
+	Components []string
+	// Objects are the Klipper printer objects, from /printer/objects/list.
+	// Empty when Klippy isn't ready.
+	Objects []string
+}
+
+// HasComponent reports whether the Moonraker component is loaded.
+func (t TargetInfo) HasComponent(component string) bool {
+	return slices.Contains(t.Components, component)
+}
+
+// HasObject reports whether the Klipper printer object exists.
+func (t TargetInfo) HasObject(object string) bool {
+	return slices.Contains(t.Objects, object)
+}
+
+var (
+	modulesMu         sync.RWMutex
+	registeredModules = map[string]Module{}
+)
+
+// RegisterModule makes a module available to probes under its name. It panics
+// if a module with the same name is already registered.
+func RegisterModule(m Module) {
+	modulesMu.Lock()
+	defer modulesMu.Unlock()
+	if _, ok := registeredModules[m.Name()]; ok {
+		panic(fmt.Sprintf("collector: module %s is already registered", m.Name()))
+	}
+	registeredModules[m.Name()] = m
+}
+
+// LookupModule returns the registered module with the name.
+func LookupModule(name string) (Module, bool) {
+	modulesMu.RLock()
+	defer modulesMu.RUnlock()
+	m, ok := registeredModules[name]
+	return m, ok
+}
+
+// Modules returns the registered modules, sorted by name.
+func Modules() []Module {
+	modulesMu.RLock()
+	defer modulesMu.RUnlock()
+	registered := make([]Module, 0, len(registeredModules))
+	for _, m := range registeredModules {
+		registered = append(registered, m)
+	}
+	sort.Slice(registered, func(i, j int) bool {
+		return registered[i].Name() < registered[j].Name()
+	})
+	return registered
+}
+
+// DefaultModules returns the names of the modules collected when a probe
+// doesn't set the modules.
+func DefaultModules() []string {
+	var names []string
+	for _, m := range Modules() {
+		if m.DefaultEnabled() {
+			names = append(names, m.Name())
+		}
+	}
+	return names
+}
+
+// builtinModule is a module of this package, with its metrics defined with
+// newGauge and newCounter.
+type builtinModule struct {
+	name           string
+	defaultEnabled bool
+	endpoints      []string
+	// detect is nil for modules enabled by `auto` when defaultEnabled
+	detect  func(target TargetInfo) bool
+	collect func(c Collector, ch chan<- prometheus.Metric) error
+}
+
+func (m builtinModule) Name() string {
+	return m.name
+}
+
+func (m builtinModule) DefaultEnabled() bool {
+	return m.defaultEnabled
+}
+
+func (m builtinModule) Endpoints() []string {
+	return m.endpoints
+}
+
+func (m builtinModule) Detect(target TargetInfo) bool {
+	if m.detect == nil {
+		return m.defaultEnabled
+	}
+	return m.detect(target)
+}
+
+func (m builtinModule) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range metricDescs {
+		if d.module == m.name {
+			ch <- d.desc
+		}
+	}
+}
+
+func (m builtinModule) Collect(c Collector, ch chan<- prometheus.Metric) error {
+	return m.collect(c, ch)
+}
+
+// Fetch sends a GET request for the Moonraker endpoint to the target of the
+// collector and JSON-unmarshals the response, for use by modules. Requests go
+// through the retries, circuit breaker, and response cache of the collector, and
+// modules requesting the same endpoint during a probe share a single request.
+func (c Collector) Fetch(urlPath string, response interface{}) error {
+	return c.Client().Get(c.ctx, urlPath, response)
+}
+
+// FetchPost sends a POST request with a JSON body for the Moonraker endpoint to
+// the target of the collector and JSON-unmarshals the response, for use by modules.
+func (c Collector) FetchPost(urlPath string, body interface{}, response interface{}) error {
+	return c.Client().Post(c.ctx, urlPath, body, response)
+}
@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// Option configures a Collector created with New.
+type Option func(*Collector)
+
+// WithContext sets the context that bounds the Moonraker requests of every
+// Collect call, e.g. the probe request context.
+func WithContext(ctx context.Context) Option {
+	return func(c *Collector) {
+		c.ctx = ctx
+	}
+}
+
+// WithModules sets the names of the modules to collect, `auto` for the modules
+// detected on the target, or `-module` to exclude a module. The default modules
+// are collected when no modules, or only exclusions, are set.
+func WithModules(modules ...string) Option {
+	return func(c *Collector) {
+		c.modules = modules
+	}
+}
+
+// WithClientConfig sets the settings used to connect and authenticate to
+// Moonraker.
+func WithClientConfig(config ClientConfig) Option {
+	return func(c *Collector) {
+		c.config = config
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for Moonraker requests, instead of a
+// client created from the TLS settings of the ClientConfig.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Collector) {
+		c.httpClient = client
+	}
+}
+
+// WithLogger sets the logger. Log entries are discarded by default.
+func WithLogger(logger log.FieldLogger) Option {
+	return func(c *Collector) {
+		c.logger = logger
+	}
+}
+
+// WithConstLabels adds the labels to every metric collected.
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(c *Collector) {
+		c.constLabels = labels
+	}
+}
+
+// WithLabelSanitizer sets the function used to sanitize names from Moonraker,
+// such as sensor and device names, for use as label values. GetValidLabelName
+// is used by default.
+func WithLabelSanitizer(sanitize func(string) string) Option {
+	return func(c *Collector) {
+		c.labelName = sanitize
+	}
+}
+
+// WithMetricFilter sets the filter selecting the metrics collected.
+func WithMetricFilter(filter MetricFilter) Option {
+	return func(c *Collector) {
+		c.filter = filter
+	}
+}
+
+// WithTimeout bounds every Collect call, in addition to the context.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Collector) {
+		c.timeout = timeout
+	}
+}
+
+// WithState shares the state between the collectors, so that responses, login
+// sessions, circuit breakers, and subscriptions are reused between probes of
+// the same target.
+func WithState(state *State) Option {
+	return func(c *Collector) {
+		c.state = state
+	}
+}
@@ -4,13 +4,12 @@ package collector
 
 import (
 	"encoding/json"
-	"fmt"
 	"regexp"
 	"strings"
-	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
 
 	"github.com/mitchellh/mapstructure"
 )
@@ -333,36 +332,34 @@ func (f *PrinterObjectStatus) UnmarshalJSON(bs []byte) (err error) {
 	return err
 }
 
-type PrinterObjectsList struct {
-	Result struct {
-		Objects []string `json:"objects"`
-	} `json:"result"`
+// customSensors are the printer objects with custom names found on a printer.
+type customSensors struct {
+	mcus               []string
+	temperatureSensors []string
+	temperatureFans    []string
+	temperatureProbes  []string
+	outputPins         []string
+	genericFans        []string
+	controllerFans     []string
+	heaterFans         []string
+	filamentSensors    [][]string
+	genericHeaters     []string
+	tmcSensors         []string
+	// lastUsed is guarded by State.customSensorsMu
+	lastUsed time.Time
 }
 
 var (
-	filamentSensorRegex      *regexp.Regexp = regexp.MustCompile("^filament_(switch|motion)_sensor ")
-	mcuRegex                 *regexp.Regexp = regexp.MustCompile("^mcu(?P<label> [a-zA-Z0-9_]+)?")
-	customSensorsMu          sync.Mutex
-	customMicrocontrollers   map[string][]string   = make(map[string][]string)
-	customTemperatureSensors map[string][]string   = make(map[string][]string)
-	customTemperatureFans    map[string][]string   = make(map[string][]string)
-	customTemperatureProbes  map[string][]string   = make(map[string][]string)
-	customOutputPins         map[string][]string   = make(map[string][]string)
-	customGenericFans        map[string][]string   = make(map[string][]string)
-	customControllerFans     map[string][]string   = make(map[string][]string)
-	customHeaterFans         map[string][]string   = make(map[string][]string)
-	customFilamentSensors    map[string][][]string = make(map[string][][]string)
-	customGenericHeaters     map[string][]string   = make(map[string][]string)
-	customTmcSensors         map[string][]string   = make(map[string][]string)
+	filamentSensorRegex *regexp.Regexp = regexp.MustCompile("^filament_(switch|motion)_sensor ")
+	mcuRegex            *regexp.Regexp = regexp.MustCompile("^mcu(?P<label> [a-zA-Z0-9_]+)?")
 )
 
 // fetchCustomSensors queries klipper for the complete list and printer objects and
 // returns the subset of `temperature_sensor`, `temperature_fan`, `output_pin`,
 // `fan_generic`, `controller_fan`, and `filament_*_sensor` objects that have custom names.
 func (c Collector) fetchCustomSensors() (*[]string, *[]string, *[]string, *[]string, *[]string, *[]string, *[]string, *[]string, *[][]string, *[]string, *[]string, error) {
-	var response PrinterObjectsList
-	if err := c.fetchFromMoonraker("/printer/objects/list", &response); err != nil {
-		log.Error(err)
+	objects, err := c.Client().PrinterObjectsList(c.ctx)
+	if err != nil {
 		return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 
@@ -377,631 +374,621 @@ func (c Collector) fetchCustomSensors() (*[]string, *[]string, *[]string, *[]str
 	filamentSensors := [][]string{}
 	genericHeaters := []string{}
 	tmcSensors := []string{}
-	for o := range response.Result.Objects {
+	for o := range objects {
 		// find mcus
-		mcuMatch := mcuRegex.FindStringSubmatch(response.Result.Objects[o])
+		mcuMatch := mcuRegex.FindStringSubmatch(objects[o])
 		if mcuMatch != nil {
 			groupMatchIndex := mcuRegex.SubexpIndex("label")
 			if mcuMatch[groupMatchIndex] == "" {
-				microcontrollers = append(microcontrollers, response.Result.Objects[o])
+				microcontrollers = append(microcontrollers, objects[o])
 			} else {
 				microcontrollers = append(microcontrollers, strings.TrimSpace(mcuMatch[groupMatchIndex]))
 			}
 		}
 		// find temperature_sensor
-		if strings.HasPrefix(response.Result.Objects[o], "temperature_sensor ") {
-			temperatureSensors = append(temperatureSensors, strings.Replace(response.Result.Objects[o], "temperature_sensor ", "", 1))
+		if strings.HasPrefix(objects[o], "temperature_sensor ") {
+			temperatureSensors = append(temperatureSensors, strings.Replace(objects[o], "temperature_sensor ", "", 1))
 		}
 		// find temperature_fan
-		if strings.HasPrefix(response.Result.Objects[o], "temperature_fan ") {
-			temperatureFans = append(temperatureFans, strings.Replace(response.Result.Objects[o], "temperature_fan ", "", 1))
+		if strings.HasPrefix(objects[o], "temperature_fan ") {
+			temperatureFans = append(temperatureFans, strings.Replace(objects[o], "temperature_fan ", "", 1))
 		}
 		// find temperature_probe
-		if strings.HasPrefix(response.Result.Objects[o], "temperature_probe ") {
-			temperatureProbes = append(temperatureProbes, strings.Replace(response.Result.Objects[o], "temperature_probe ", "", 1))
+		if strings.HasPrefix(objects[o], "temperature_probe ") {
+			temperatureProbes = append(temperatureProbes, strings.Replace(objects[o], "temperature_probe ", "", 1))
 		}
 		// find output_pin
-		if strings.HasPrefix(response.Result.Objects[o], "output_pin ") {
-			outputPins = append(outputPins, strings.Replace(response.Result.Objects[o], "output_pin ", "", 1))
+		if strings.HasPrefix(objects[o], "output_pin ") {
+			outputPins = append(outputPins, strings.Replace(objects[o], "output_pin ", "", 1))
 		}
 		// find fan_generic
-		if strings.HasPrefix(response.Result.Objects[o], "fan_generic ") {
-			genericFans = append(genericFans, strings.Replace(response.Result.Objects[o], "fan_generic ", "", 1))
+		if strings.HasPrefix(objects[o], "fan_generic ") {
+			genericFans = append(genericFans, strings.Replace(objects[o], "fan_generic ", "", 1))
 		}
 		// find controller_fan
-		if strings.HasPrefix(response.Result.Objects[o], "controller_fan ") {
-			controllerFans = append(controllerFans, strings.Replace(response.Result.Objects[o], "controller_fan ", "", 1))
+		if strings.HasPrefix(objects[o], "controller_fan ") {
+			controllerFans = append(controllerFans, strings.Replace(objects[o], "controller_fan ", "", 1))
 		}
 		// find heater_fan
-		if strings.HasPrefix(response.Result.Objects[o], "heater_fan ") {
-			heaterFans = append(heaterFans, strings.Replace(response.Result.Objects[o], "heater_fan ", "", 1))
+		if strings.HasPrefix(objects[o], "heater_fan ") {
+			heaterFans = append(heaterFans, strings.Replace(objects[o], "heater_fan ", "", 1))
 		}
 		// find filament_*_sensor
-		if filamentSensorRegex.MatchString((response.Result.Objects[o])) {
+		if filamentSensorRegex.MatchString((objects[o])) {
 			// The first element of the slice is the type of the sensor ("filament_{switch,motion}_sensor"),
 			// and the second is the custom sensor's name itself
 			filamentSensors = append(filamentSensors, []string{
-				strings.TrimSpace(filamentSensorRegex.FindString(response.Result.Objects[o])),
-				filamentSensorRegex.ReplaceAllString(response.Result.Objects[o], ""),
+				strings.TrimSpace(filamentSensorRegex.FindString(objects[o])),
+				filamentSensorRegex.ReplaceAllString(objects[o], ""),
 			})
 		}
 		// find heater_generic
-		if strings.HasPrefix(response.Result.Objects[o], "heater_generic ") {
-			genericHeaters = append(genericHeaters, strings.Replace(response.Result.Objects[o], "heater_generic ", "", 1))
+		if strings.HasPrefix(objects[o], "heater_generic ") {
+			genericHeaters = append(genericHeaters, strings.Replace(objects[o], "heater_generic ", "", 1))
 		}
 		// find tmc sensors
-		if strings.HasPrefix(response.Result.Objects[o], "tmc") {
+		if strings.HasPrefix(objects[o], "tmc") {
 			// We need full name as the stepper type is part of the name
-			tmcSensors = append(tmcSensors, response.Result.Objects[o])
+			tmcSensors = append(tmcSensors, objects[o])
 		}
 	}
 
 	return &microcontrollers, &temperatureSensors, &temperatureFans, &temperatureProbes, &outputPins, &genericFans, &controllerFans, &heaterFans, &filamentSensors, &genericHeaters, &tmcSensors, nil
 }
 
-func (c Collector) fetchMoonrakerPrinterObjects() (*PrinterObjectResponse, error) {
+// printerObjects returns all the printer objects collected by the
+// `printer_objects` module, including the custom named sensors found on the
+// printer.
+func (c Collector) printerObjects() (moonraker.Objects, error) {
 
 	// Get the list of custom sensors if not already set. This saves fetching the full
-	// list on every poll, but any new sensors will only be added is the exporter is restarted.
+	// list on every poll, but any new sensors will only be added once the exporter is
+	// restarted, or the target was not probed for stateIdleTimeout.
 	// Double-checked locking: the check and HTTP fetch happen outside the mutex so that
 	// distinct targets can initialize in parallel; only the map writes are serialized.
-	customSensorsMu.Lock()
-	_, ok := customTemperatureSensors[c.target]
-	customSensorsMu.Unlock()
+	state := c.state
+	state.customSensorsMu.Lock()
+	sensors, ok := state.customSensors[c.target]
+	if ok {
+		sensors.lastUsed = time.Now()
+	}
+	state.customSensorsMu.Unlock()
 
 	if !ok {
 		mcus, ts, tf, tp, op, gf, cf, hf, fs, gh, tmc, err := c.fetchCustomSensors()
 		if err != nil {
-			log.Error(err)
 			return nil, err
 		}
-		log.Infof("Found custom sensors: %+v %+v %+v %+v %+v %+v %+v %+v %+v %+v", mcus, ts, tf, tp, op, gf, cf, hf, fs, gh)
-
-		customSensorsMu.Lock()
-		if _, ok := customTemperatureSensors[c.target]; !ok {
-			customMicrocontrollers[c.target] = *mcus
-			customTemperatureSensors[c.target] = *ts
-			customTemperatureFans[c.target] = *tf
-			customTemperatureProbes[c.target] = *tp
-			customOutputPins[c.target] = *op
-			customGenericFans[c.target] = *gf
-			customControllerFans[c.target] = *cf
-			customHeaterFans[c.target] = *hf
-			customFilamentSensors[c.target] = *fs
-			customGenericHeaters[c.target] = *gh
-			customTmcSensors[c.target] = *tmc
+		c.logger.Infof("Found custom sensors: %+v %+v %+v %+v %+v %+v %+v %+v %+v %+v", mcus, ts, tf, tp, op, gf, cf, hf, fs, gh)
+
+		state.customSensorsMu.Lock()
+		if sensors, ok = state.customSensors[c.target]; !ok {
+			for target, idle := range state.customSensors {
+				if time.Since(idle.lastUsed) > stateIdleTimeout {
+					delete(state.customSensors, target)
+				}
+			}
+			sensors = &customSensors{
+				mcus:               *mcus,
+				temperatureSensors: *ts,
+				temperatureFans:    *tf,
+				temperatureProbes:  *tp,
+				outputPins:         *op,
+				genericFans:        *gf,
+				controllerFans:     *cf,
+				heaterFans:         *hf,
+				filamentSensors:    *fs,
+				genericHeaters:     *gh,
+				tmcSensors:         *tmc,
+				lastUsed:           time.Now(),
+			}
+			state.customSensors[c.target] = sensors
+		}
+		state.customSensorsMu.Unlock()
+	}
+
+	// the sensor lists are never modified once stored, so they are read without the lock
+	mcus := sensors.mcus
+	temperatureSensors := sensors.temperatureSensors
+	temperatureFans := sensors.temperatureFans
+	temperatureProbes := sensors.temperatureProbes
+	outputPins := sensors.outputPins
+	genericFans := sensors.genericFans
+	controllerFans := sensors.controllerFans
+	heaterFans := sensors.heaterFans
+	filamentSensors := sensors.filamentSensors
+	genericHeaters := sensors.genericHeaters
+	tmcSensors := sensors.tmcSensors
+
+	objects := moonraker.Objects{}
+	for _, query := range []string{
+		gcodeMoveQuery,
+		toolheadQuery,
+		extruderQuery,
+		heaterBedQuery,
+		fanQuery,
+		idleTimeoutQuery,
+		virtualSdCardQuery,
+		printStatsQuery,
+		displayStatusQuery,
+		heaterQuery,
+		webhooksQuery,
+		pauseResumeQuery,
+		inputShaperQuery,
+		firmwareRetractionQuery,
+	} {
+		name, attributes, found := strings.Cut(query, "=")
+		objects[name] = nil
+		if found {
+			objects[name] = strings.Split(attributes, ",")
 		}
-		customSensorsMu.Unlock()
 	}
 
-	mcuQuery := ""
-	for mcu := range customMicrocontrollers[c.target] {
-		if customMicrocontrollers[c.target][mcu] == "mcu" {
-			mcuQuery += "&mcu=last_stats"
+	for mcu := range mcus {
+		if mcus[mcu] == "mcu" {
+			objects["mcu"] = []string{"last_stats"}
 		} else {
-			mcuQuery += "&mcu%20" + customMicrocontrollers[c.target][mcu] + "=last_stats"
+			objects["mcu "+mcus[mcu]] = []string{"last_stats"}
 		}
 	}
 
-	customSensorsQuery := ""
-	for ts := range customTemperatureSensors[c.target] {
-		customSensorsQuery += "&temperature_sensor%20" + customTemperatureSensors[c.target][ts]
+	for ts := range temperatureSensors {
+		objects["temperature_sensor "+temperatureSensors[ts]] = nil
 	}
-	for tf := range customTemperatureFans[c.target] {
-		customSensorsQuery += "&temperature_fan%20" + customTemperatureFans[c.target][tf]
+	for tf := range temperatureFans {
+		objects["temperature_fan "+temperatureFans[tf]] = nil
 	}
-	for tp := range customTemperatureProbes[c.target] {
-		customSensorsQuery += "&temperature_probe%20" + customTemperatureProbes[c.target][tp]
+	for tp := range temperatureProbes {
+		objects["temperature_probe "+temperatureProbes[tp]] = nil
 	}
-	for op := range customOutputPins[c.target] {
-		customSensorsQuery += "&output_pin%20" + customOutputPins[c.target][op]
+	for op := range outputPins {
+		objects["output_pin "+outputPins[op]] = nil
 	}
-	for gf := range customGenericFans[c.target] {
-		customSensorsQuery += "&fan_generic%20" + customGenericFans[c.target][gf]
+	for gf := range genericFans {
+		objects["fan_generic "+genericFans[gf]] = nil
 	}
-	for cf := range customControllerFans[c.target] {
-		customSensorsQuery += "&controller_fan%20" + customControllerFans[c.target][cf]
+	for cf := range controllerFans {
+		objects["controller_fan "+controllerFans[cf]] = nil
 	}
-	for hf := range customHeaterFans[c.target] {
-		customSensorsQuery += "&heater_fan%20" + customHeaterFans[c.target][hf]
+	for hf := range heaterFans {
+		objects["heater_fan "+heaterFans[hf]] = nil
 	}
-	for fs := range customFilamentSensors[c.target] {
-		customSensorsQuery += fmt.Sprintf("&%s%%20%s", customFilamentSensors[c.target][fs][0], customFilamentSensors[c.target][fs][1])
+	for fs := range filamentSensors {
+		objects[filamentSensors[fs][0]+" "+filamentSensors[fs][1]] = nil
 	}
-	for gh := range customGenericHeaters[c.target] {
-		customSensorsQuery += "&heater_generic%20" + customGenericHeaters[c.target][gh]
+	for gh := range genericHeaters {
+		objects["heater_generic "+genericHeaters[gh]] = nil
 	}
-	for tmc := range customTmcSensors[c.target] {
-		customSensorsQuery += "&" + strings.ReplaceAll(customTmcSensors[c.target][tmc], " ", "%20")
+	for tmc := range tmcSensors {
+		objects[tmcSensors[tmc]] = nil
 	}
 
-	urlPath := "/printer/objects/query" +
-		"?" + gcodeMoveQuery +
-		"&" + toolheadQuery +
-		"&" + extruderQuery +
-		"&" + heaterBedQuery +
-		"&" + fanQuery +
-		"&" + idleTimeoutQuery +
-		"&" + virtualSdCardQuery +
-		"&" + printStatsQuery +
-		"&" + displayStatusQuery +
-		"&" + heaterQuery +
-		"&" + webhooksQuery +
-		"&" + pauseResumeQuery +
-		"&" + inputShaperQuery +
-		"&" + firmwareRetractionQuery +
-		mcuQuery +
-		customSensorsQuery
-
-	var response PrinterObjectResponse
-	if err := c.fetchFromMoonraker(urlPath, &response); err != nil {
-		return nil, err
+	// printer objects with all their metrics excluded aren't queried or decoded
+	for object := range objects {
+		if !c.printerObjectWanted(object) {
+			delete(objects, object)
+		}
 	}
 
-	return &response, nil
+	return objects, nil
 }
 
-type QueryEndstopsResponse struct {
-	Result map[string]string `json:"result"`
+// printerObjectWanted reports whether any metric of the printer object can be
+// collected with the metric filter.
+func (c Collector) printerObjectWanted(object string) bool {
+	kind, _, _ := strings.Cut(object, " ")
+	switch {
+	case strings.HasPrefix(kind, "tmc"):
+		kind = "tmc"
+	case filamentSensorRegex.MatchString(object):
+		kind = "filament_sensor"
+	}
+	descs, ok := printerObjectDescs[kind]
+	if !ok {
+		return true
+	}
+	for _, d := range descs {
+		if c.filter.wantsMetric(d.name) {
+			return true
+		}
+	}
+	return false
 }
 
-func (c Collector) fetchMoonrakerQueryEndstops() (map[string]string, error) {
-	var response QueryEndstopsResponse
-	if err := c.fetchFromMoonraker("/printer/query_endstops/status", &response); err != nil {
+// fetchMoonrakerPrinterObjects returns the printer object status, served from
+// the websocket subscription when enabled and otherwise queried from Moonraker.
+func (c Collector) fetchMoonrakerPrinterObjects() (*PrinterObjectResponse, error) {
+	if c.config.Subscribe {
+		if response, ok := c.subscribedPrinterObjects(); ok {
+			return response, nil
+		}
+		c.logger.Debugf("Websocket subscription for %s not ready, querying printer objects", c.target)
+	}
+
+	objects, err := c.printerObjects()
+	if err != nil {
 		return nil, err
 	}
-	return response.Result, nil
+
+	var response PrinterObjectResponse
+	if err := c.Client().QueryPrinterObjects(c.ctx, objects, &response.Result.Status); err != nil {
+		return nil, err
+	}
+
+	return &response, nil
 }
 
-func (c Collector) collectQueryEndstops(ch chan<- prometheus.Metric) {
-	endstops, err := c.fetchMoonrakerQueryEndstops()
+// Metrics of the query_endstops module
+var (
+	endstopTriggeredDesc = newGauge("query_endstops", "klipper_endstop_triggered", "Whether an endstop is triggered (1) or not (0).", "endstop")
+)
+
+func (c Collector) collectQueryEndstops(ch chan<- prometheus.Metric) error {
+	endstops, err := c.Client().QueryEndstops(c.ctx)
 	if err != nil {
-		log.Error(err)
-		return
+		return err
 	}
-	endstopLabels := []string{"endstop"}
-	endstopDesc := prometheus.NewDesc("klipper_endstop_triggered", "Whether an endstop is triggered (1) or not (0).", endstopLabels, nil)
 	for name, state := range endstops {
-		ch <- prometheus.MustNewConstMetric(
-			endstopDesc,
-			prometheus.GaugeValue,
-			boolToFloat64(state == "TRIGGERED"),
-			GetValidLabelName(name))
+		endstopTriggeredDesc.emit(ch, boolToFloat64(state == "TRIGGERED"), c.labelName(name))
 	}
+	return nil
 }
 
-func (c Collector) collectPrinterObjects(ch chan<- prometheus.Metric) {
+// Metrics of the printer_objects module
+var (
+	gcodeSpeedFactorDesc                   = newGauge("printer_objects", "klipper_gcode_speed_factor", "Klipper gcode speed factor.")
+	gcodeSpeedDesc                         = newGauge("printer_objects", "klipper_gcode_speed", "Klipper gcode speed.")
+	gcodeExtrudeFactorDesc                 = newGauge("printer_objects", "klipper_gcode_extrude_factor", "Klipper gcode extrude factor.")
+	gcodePositionXDesc                     = newGauge("printer_objects", "klipper_gcode_position_x", "Klipper gcode position X axis.")
+	gcodePositionYDesc                     = newGauge("printer_objects", "klipper_gcode_position_y", "Klipper gcode position Y axis.")
+	gcodePositionZDesc                     = newGauge("printer_objects", "klipper_gcode_position_z", "Klipper gcode position Z axis.")
+	gcodePositionEDesc                     = newGauge("printer_objects", "klipper_gcode_position_e", "Klipper gcode position for extruder.")
+	toolheadPrintTimeDesc                  = newGauge("printer_objects", "klipper_toolhead_print_time", "Klipper toolhead print time.")
+	toolheadEstimatedPrintTimeDesc         = newGauge("printer_objects", "klipper_toolhead_estimated_print_time", "Klipper estimated print time.")
+	toolheadMaxVelocityDesc                = newGauge("printer_objects", "klipper_toolhead_max_velocity", "Klipper toolhead max velocity.")
+	toolheadMaxAccelDesc                   = newGauge("printer_objects", "klipper_toolhead_max_accel", "Klipper toolhead max acceleration.")
+	toolheadMaxAccelToDecelDesc            = newGauge("printer_objects", "klipper_toolhead_max_accel_to_decel", "Klipper toolhead max acceleration to deceleration.")
+	toolheadSquareCornerVelocityDesc       = newGauge("printer_objects", "klipper_toolhead_square_corner_velocity", "Klipper toolhead square corner velocity.")
+	toolheadHomedAxesInfoDesc              = newGauge("printer_objects", "klipper_toolhead_homed_axes_info", "A homed axis on the toolhead.", "axis")
+	toolheadStallsTotalDesc                = newCounter("printer_objects", "klipper_toolhead_stalls_total", "Total number of toolhead stalls.")
+	extruderTemperatureDesc                = newGauge("printer_objects", "klipper_extruder_temperature", "Klipper extruder temperature.")
+	extruderTargetDesc                     = newGauge("printer_objects", "klipper_extruder_target", "Klipper extruder target.")
+	extruderPowerDesc                      = newGauge("printer_objects", "klipper_extruder_power", "Klipper extruder power.")
+	extruderPressureAdvanceDesc            = newGauge("printer_objects", "klipper_extruder_pressure_advance", "Klipper extruder pressure advance.")
+	extruderSmoothTimeDesc                 = newGauge("printer_objects", "klipper_extruder_smooth_time", "Klipper extruder smooth time.")
+	heaterBedTemperatureDesc               = newGauge("printer_objects", "klipper_heater_bed_temperature", "Klipper heater bed temperature.")
+	heaterBedTargetDesc                    = newGauge("printer_objects", "klipper_heater_bed_target", "Klipper heater bed target.")
+	heaterBedPowerDesc                     = newGauge("printer_objects", "klipper_heater_bed_power", "Klipper heater bed power.")
+	fanSpeedDesc                           = newGauge("printer_objects", "klipper_fan_speed", "Klipper fan speed.")
+	fanRpmDesc                             = newGauge("printer_objects", "klipper_fan_rpm", "Klipper fan rpm.")
+	printingTimeDesc                       = newCounter("printer_objects", "klipper_printing_time", "The amount of time the printer has been in the Printing state.")
+	idleTimeoutStateInfoDesc               = newGauge("printer_objects", "klipper_idle_timeout_state_info", "The current idle timeout state of the printer.", "state")
+	printFileProgressDesc                  = newCounter("printer_objects", "klipper_print_file_progress", "The print progress reported as a percentage of the file read.")
+	printFilePositionDesc                  = newCounter("printer_objects", "klipper_print_file_position", "The current file position in bytes.")
+	sdcardActiveDesc                       = newGauge("printer_objects", "klipper_sdcard_active", "Indicates whether the virtual SD card is actively being read (1) or not (0).")
+	printTotalDurationDesc                 = newCounter("printer_objects", "klipper_print_total_duration", "The total time (in seconds) elapsed since a print has started.")
+	printPrintDurationDesc                 = newCounter("printer_objects", "klipper_print_print_duration", "The total time spent printing (in seconds).")
+	printFilamentUsedDesc                  = newCounter("printer_objects", "klipper_print_filament_used", "The amount of filament used during the current print (in mm)..")
+	printStateInfoDesc                     = newGauge("printer_objects", "klipper_print_state_info", "The current print state of the printer.", "state")
+	printingDesc                           = newGauge("printer_objects", "klipper_printing", "Indicates whether the printer is currently printing (1) or not (0).")
+	webhooksStateInfoDesc                  = newGauge("printer_objects", "klipper_webhooks_state_info", "The current state of the Klipper webhooks server.", "state")
+	pauseResumeIsPausedDesc                = newGauge("printer_objects", "klipper_pause_resume_is_paused", "Indicates whether the print is paused (1) or not (0).")
+	printGcodeProgressDesc                 = newCounter("printer_objects", "klipper_print_gcode_progress", "The percentage of print progress, as reported by M73.")
+	inputShaperFrequencyXDesc              = newGauge("printer_objects", "klipper_input_shaper_frequency_x", "Input shaper frequency for X axis.")
+	inputShaperFrequencyYDesc              = newGauge("printer_objects", "klipper_input_shaper_frequency_y", "Input shaper frequency for Y axis.")
+	inputShaperDampingRatioXDesc           = newGauge("printer_objects", "klipper_input_shaper_damping_ratio_x", "Input shaper damping ratio for X axis.")
+	inputShaperDampingRatioYDesc           = newGauge("printer_objects", "klipper_input_shaper_damping_ratio_y", "Input shaper damping ratio for Y axis.")
+	firmwareRetractLengthDesc              = newGauge("printer_objects", "klipper_firmware_retract_length", "Firmware retraction length in mm.")
+	firmwareRetractSpeedDesc               = newGauge("printer_objects", "klipper_firmware_retract_speed", "Firmware retraction speed in mm/min.")
+	firmwareUnretractExtraLengthDesc       = newGauge("printer_objects", "klipper_firmware_unretract_extra_length", "Firmware unretract extra length in mm.")
+	firmwareUnretractSpeedDesc             = newGauge("printer_objects", "klipper_firmware_unretract_speed", "Firmware unretract speed in mm/min.")
+	mcuAwakeDesc                           = newGauge("printer_objects", "klipper_mcu_awake", "Klipper mcu awake.", "mcu")
+	mcuTaskAvgDesc                         = newGauge("printer_objects", "klipper_mcu_task_avg", "Klipper mcu task average.", "mcu")
+	mcuTaskStddevDesc                      = newGauge("printer_objects", "klipper_mcu_task_stddev", "Klipper mcu task standard deviation.", "mcu")
+	mcuWriteBytesDesc                      = newGauge("printer_objects", "klipper_mcu_write_bytes", "Klipper mcu write bytes.", "mcu")
+	mcuReadBytesDesc                       = newGauge("printer_objects", "klipper_mcu_read_bytes", "Klipper mcu read bytes.", "mcu")
+	mcuRetransmitBytesDesc                 = newGauge("printer_objects", "klipper_mcu_retransmit_bytes", "Klipper mcu retransmit bytes.", "mcu")
+	mcuInvalidBytesDesc                    = newGauge("printer_objects", "klipper_mcu_invalid_bytes", "Klipper mcu invalid bytes.", "mcu")
+	mcuSendSeqDesc                         = newGauge("printer_objects", "klipper_mcu_send_seq", "Klipper mcu send sequence.", "mcu")
+	mcuReceiveSeqDesc                      = newGauge("printer_objects", "klipper_mcu_receive_seq", "Klipper mcu receive sequence.", "mcu")
+	mcuRetransmitSeqDesc                   = newGauge("printer_objects", "klipper_mcu_retransmit_seq", "Klipper mcu retransmit sequence.", "mcu")
+	mcuSrttDesc                            = newGauge("printer_objects", "klipper_mcu_srtt", "Klipper mcu smoothed round trip time.", "mcu")
+	mcuRttvarDesc                          = newGauge("printer_objects", "klipper_mcu_rttvar", "Klipper mcu round trip time variance.", "mcu")
+	mcuRtoDesc                             = newGauge("printer_objects", "klipper_mcu_rto", "Klipper mcu retransmission timeouts.", "mcu")
+	mcuReadyBytesDesc                      = newGauge("printer_objects", "klipper_mcu_ready_bytes", "Klipper mcu ready bytes.", "mcu")
+	mcuStalledBytesDesc                    = newGauge("printer_objects", "klipper_mcu_stalled_bytes", "Klipper mcu stalled bytes.", "mcu")
+	mcuClockFrequencyDesc                  = newGauge("printer_objects", "klipper_mcu_clock_frequency", "Klipper mcu clock frequency.", "mcu")
+	temperatureSensorTemperatureDesc       = newGauge("printer_objects", "klipper_temperature_sensor_temperature", "The temperature of the temperature sensor", "sensor")
+	temperatureSensorMeasuredMinTempDesc   = newGauge("printer_objects", "klipper_temperature_sensor_measured_min_temp", "The measured minimum temperature of the temperature sensor", "sensor")
+	temperatureSensorMeasuredMaxTempDesc   = newGauge("printer_objects", "klipper_temperature_sensor_measured_max_temp", "The measured maximum temperature of the temperature sensor", "sensor")
+	temperatureFanSpeedDesc                = newGauge("printer_objects", "klipper_temperature_fan_speed", "The speed of the temperature fan", "fan")
+	temperatureFanTemperatureDesc          = newGauge("printer_objects", "klipper_temperature_fan_temperature", "The temperature of the temperature fan", "fan")
+	temperatureFanTargetDesc               = newGauge("printer_objects", "klipper_temperature_fan_target", "The target temperature for the temperature fan", "fan")
+	temperatureFanRpmDesc                  = newGauge("printer_objects", "klipper_temperature_fan_rpm", "The RPM of the temperature fan", "fan")
+	temperatureProbeTemperatureDesc        = newGauge("printer_objects", "klipper_temperature_probe_temperature", "The temperature of the temperature probe", "sensor")
+	temperatureProbeMeasuredMinTempDesc    = newGauge("printer_objects", "klipper_temperature_probe_measured_min_temp", "The measured minimum temperature of the temperature probe", "sensor")
+	temperatureProbeMeasuredMaxTempDesc    = newGauge("printer_objects", "klipper_temperature_probe_measured_max_temp", "The measured maximum temperature of the temperature probe", "sensor")
+	temperatureProbeEstimatedExpansionDesc = newGauge("printer_objects", "klipper_temperature_probe_estimated_expansion", "The estimated of the temperature probe", "sensor")
+	outputPinValueDesc                     = newGauge("printer_objects", "klipper_output_pin_value", "The value of the output pin", "pin")
+	genericFanSpeedDesc                    = newGauge("printer_objects", "klipper_generic_fan_speed", "The speed of the generic fan", "fan")
+	genericFanRpmDesc                      = newGauge("printer_objects", "klipper_generic_fan_rpm", "The RPM of the generic fan", "fan")
+	controllerFanSpeedDesc                 = newGauge("printer_objects", "klipper_controller_fan_speed", "The speed of the controller fan", "fan")
+	controllerFanRpmDesc                   = newGauge("printer_objects", "klipper_controller_fan_rpm", "The RPM of the controller fan", "fan")
+	heaterFanSpeedDesc                     = newGauge("printer_objects", "klipper_heater_fan_speed", "The speed of the heater fan", "fan")
+	heaterFanRpmDesc                       = newGauge("printer_objects", "klipper_heater_fan_rpm", "The RPM of the heater fan", "fan")
+	filamentSensorDetectedDesc             = newGauge("printer_objects", "klipper_filament_sensor_detected", "Whether filament presence is detected by the sensor", "sensor")
+	filamentSensorEnabledDesc              = newGauge("printer_objects", "klipper_filament_sensor_enabled", "Whether the filament sensor is enabled or not", "sensor")
+	genericHeaterTemperatureDesc           = newGauge("printer_objects", "klipper_generic_heater_temperature", "The temperature of the generic heater", "heater")
+	genericHeaterTargetDesc                = newGauge("printer_objects", "klipper_generic_heater_target", "The target temperature of the generic heater", "heater")
+	genericHeaterPowerDesc                 = newGauge("printer_objects", "klipper_generic_heater_power", "The output power of the generic heater", "heater")
+	tmcSensorTemperatureDesc               = newGauge("printer_objects", "klipper_tmc_sensor_temperature", "The temperature of the tmc driver", "sensor")
+	tmcSensorRunCurrentDesc                = newGauge("printer_objects", "klipper_tmc_sensor_run_current", "The run current of the tmc driver", "sensor")
+	tmcSensorEnabledDesc                   = newGauge("printer_objects", "klipper_tmc_sensor_enabled", "Whether the tmc driver is enabled or not", "sensor")
+	inputShaperTypeInfoDesc                = newGauge("printer_objects", "klipper_input_shaper_type_info", "Input shaper type per axis.", "axis", "type")
+)
+
+// printerObjectDescs are the metrics of each kind of printer object, by the
+// object name without the sensor name. Objects not listed here are always
+// queried.
+var printerObjectDescs = map[string][]*metricDesc{
+	"gcode_move": {gcodeSpeedFactorDesc, gcodeSpeedDesc, gcodeExtrudeFactorDesc,
+		gcodePositionXDesc, gcodePositionYDesc, gcodePositionZDesc, gcodePositionEDesc},
+	"toolhead": {toolheadPrintTimeDesc, toolheadEstimatedPrintTimeDesc, toolheadMaxVelocityDesc, toolheadMaxAccelDesc,
+		toolheadMaxAccelToDecelDesc, toolheadSquareCornerVelocityDesc, toolheadHomedAxesInfoDesc, toolheadStallsTotalDesc},
+	"extruder":   {extruderTemperatureDesc, extruderTargetDesc, extruderPowerDesc, extruderPressureAdvanceDesc, extruderSmoothTimeDesc},
+	"heater_bed": {heaterBedTemperatureDesc, heaterBedTargetDesc, heaterBedPowerDesc},
+	"fan":        {fanSpeedDesc, fanRpmDesc},
+	"mcu": {mcuAwakeDesc, mcuTaskAvgDesc, mcuTaskStddevDesc, mcuWriteBytesDesc, mcuReadBytesDesc,
+		mcuRetransmitBytesDesc, mcuInvalidBytesDesc, mcuSendSeqDesc, mcuReceiveSeqDesc, mcuRetransmitSeqDesc,
+		mcuSrttDesc, mcuRttvarDesc, mcuRtoDesc, mcuReadyBytesDesc, mcuStalledBytesDesc, mcuClockFrequencyDesc},
+	"input_shaper": {inputShaperFrequencyXDesc, inputShaperFrequencyYDesc, inputShaperDampingRatioXDesc,
+		inputShaperDampingRatioYDesc, inputShaperTypeInfoDesc},
+	"firmware_retraction": {firmwareRetractLengthDesc, firmwareRetractSpeedDesc, firmwareUnretractExtraLengthDesc,
+		firmwareUnretractSpeedDesc},
+	"temperature_sensor": {temperatureSensorTemperatureDesc, temperatureSensorMeasuredMinTempDesc, temperatureSensorMeasuredMaxTempDesc},
+	"temperature_fan":    {temperatureFanSpeedDesc, temperatureFanTemperatureDesc, temperatureFanTargetDesc, temperatureFanRpmDesc},
+	"temperature_probe": {temperatureProbeTemperatureDesc, temperatureProbeMeasuredMinTempDesc, temperatureProbeMeasuredMaxTempDesc,
+		temperatureProbeEstimatedExpansionDesc},
+	"output_pin":      {outputPinValueDesc},
+	"fan_generic":     {genericFanSpeedDesc, genericFanRpmDesc},
+	"controller_fan":  {controllerFanSpeedDesc, controllerFanRpmDesc},
+	"heater_fan":      {heaterFanSpeedDesc, heaterFanRpmDesc},
+	"filament_sensor": {filamentSensorDetectedDesc, filamentSensorEnabledDesc},
+	"heater_generic":  {genericHeaterTemperatureDesc, genericHeaterTargetDesc, genericHeaterPowerDesc},
+	"tmc":             {tmcSensorTemperatureDesc, tmcSensorRunCurrentDesc, tmcSensorEnabledDesc},
+}
+
+func init() {
+	RegisterModule(builtinModule{
+		name:           "printer_objects",
+		defaultEnabled: false,
+		endpoints:      []string{"/printer/objects/list", "/printer/objects/query", "/websocket"},
+		detect:         func(t TargetInfo) bool { return len(t.Objects) > 0 },
+		collect:        Collector.collectPrinterObjects,
+	})
+	// the endstops can only be queried once Klippy is ready
+	RegisterModule(builtinModule{
+		name:           "query_endstops",
+		defaultEnabled: true,
+		endpoints:      []string{"/printer/query_endstops/status"},
+		detect:         func(t TargetInfo) bool { return len(t.Objects) > 0 },
+		collect:        Collector.collectQueryEndstops,
+	})
+}
+
+func (c Collector) collectPrinterObjects(ch chan<- prometheus.Metric) error {
+	if c.config.Subscribe {
+		defer c.collectSubscriptionStatus(ch)
+	}
 	result, err := c.fetchMoonrakerPrinterObjects()
 	if err != nil {
-		log.Error(err)
-		return
+		return err
 	}
 
 	// gcode_move
-	c.emitGauge(ch, "klipper_gcode_speed_factor", "Klipper gcode speed factor.", result.Result.Status.GcodeMove.SpeedFactor)
-	c.emitGauge(ch, "klipper_gcode_speed", "Klipper gcode speed.", result.Result.Status.GcodeMove.Speed)
-	c.emitGauge(ch, "klipper_gcode_extrude_factor", "Klipper gcode extrude factor.", result.Result.Status.GcodeMove.ExtrudeFactor)
+	gcodeSpeedFactorDesc.emit(ch, result.Result.Status.GcodeMove.SpeedFactor)
+	gcodeSpeedDesc.emit(ch, result.Result.Status.GcodeMove.Speed)
+	gcodeExtrudeFactorDesc.emit(ch, result.Result.Status.GcodeMove.ExtrudeFactor)
 
 	// gcode position
 	if len(result.Result.Status.GcodeMove.GcodePosition) < 4 {
-		log.Warn("Unexpected number of Gcode Position values, skipping gcode position metrics")
+		if c.printerObjectWanted("gcode_move") {
+			c.logger.Warn("Unexpected number of Gcode Position values, skipping gcode position metrics")
+		}
 	} else {
-		c.emitGauge(ch, "klipper_gcode_position_x", "Klipper gcode position X axis.", result.Result.Status.GcodeMove.GcodePosition[0])
-		c.emitGauge(ch, "klipper_gcode_position_y", "Klipper gcode position Y axis.", result.Result.Status.GcodeMove.GcodePosition[1])
-		c.emitGauge(ch, "klipper_gcode_position_z", "Klipper gcode position Z axis.", result.Result.Status.GcodeMove.GcodePosition[2])
-		c.emitGauge(ch, "klipper_gcode_position_e", "Klipper gcode position for extruder.", result.Result.Status.GcodeMove.GcodePosition[3])
+		gcodePositionXDesc.emit(ch, result.Result.Status.GcodeMove.GcodePosition[0])
+		gcodePositionYDesc.emit(ch, result.Result.Status.GcodeMove.GcodePosition[1])
+		gcodePositionZDesc.emit(ch, result.Result.Status.GcodeMove.GcodePosition[2])
+		gcodePositionEDesc.emit(ch, result.Result.Status.GcodeMove.GcodePosition[3])
 	}
 
 	// mcu
-	mcuLabels := []string{"mcu"}
-	mcuAwake := prometheus.NewDesc("klipper_mcu_awake", "Klipper mcu awake.", mcuLabels, nil)
-	mcuTaskAvg := prometheus.NewDesc("klipper_mcu_task_avg", "Klipper mcu task average.", mcuLabels, nil)
-	mcuTaskStddev := prometheus.NewDesc("klipper_mcu_task_stddev", "Klipper mcu task standard deviation.", mcuLabels, nil)
-	mcuWriteBytes := prometheus.NewDesc("klipper_mcu_write_bytes", "Klipper mcu write bytes.", mcuLabels, nil)
-	mcuReadBytes := prometheus.NewDesc("klipper_mcu_read_bytes", "Klipper mcu read bytes.", mcuLabels, nil)
-	mcuRetransmitBytes := prometheus.NewDesc("klipper_mcu_retransmit_bytes", "Klipper mcu retransmit bytes.", mcuLabels, nil)
-	mcuInvalidBytes := prometheus.NewDesc("klipper_mcu_invalid_bytes", "Klipper mcu invalid bytes.", mcuLabels, nil)
-	mcuSendSeq := prometheus.NewDesc("klipper_mcu_send_seq", "Klipper mcu send sequence.", mcuLabels, nil)
-	mcuReceiveSeq := prometheus.NewDesc("klipper_mcu_receive_seq", "Klipper mcu receive sequence.", mcuLabels, nil)
-	mcuRetransmitSeq := prometheus.NewDesc("klipper_mcu_retransmit_seq", "Klipper mcu retransmit sequence.", mcuLabels, nil)
-	mcuSrtt := prometheus.NewDesc("klipper_mcu_srtt", "Klipper mcu smoothed round trip time.", mcuLabels, nil)
-	mcuRttvar := prometheus.NewDesc("klipper_mcu_rttvar", "Klipper mcu round trip time variance.", mcuLabels, nil)
-	mcuRto := prometheus.NewDesc("klipper_mcu_rto", "Klipper mcu retransmission timeouts.", mcuLabels, nil)
-	mcuReadyBytes := prometheus.NewDesc("klipper_mcu_ready_bytes", "Klipper mcu ready bytes.", mcuLabels, nil)
-	mcuStalledBytes := prometheus.NewDesc("klipper_mcu_stalled_bytes", "Klipper mcu stalled bytes.", mcuLabels, nil)
-	mcuClockFrequency := prometheus.NewDesc("klipper_mcu_clock_frequency", "Klipper mcu clock frequency.", mcuLabels, nil)
 	for mk, mv := range result.Result.Status.Mcus {
-		sensorName := GetValidLabelName(mk)
-		ch <- prometheus.MustNewConstMetric(
-			mcuAwake,
-			prometheus.GaugeValue,
-			mv.LastStats.McuAwake,
-			sensorName)
-		ch <- prometheus.MustNewConstMetric(
-			mcuTaskAvg,
-			prometheus.GaugeValue,
-			mv.LastStats.McuTaskAvg,
-			sensorName)
-		ch <- prometheus.MustNewConstMetric(
-			mcuTaskStddev,
-			prometheus.GaugeValue,
-			mv.LastStats.McuTaskStddev,
-			sensorName)
-		ch <- prometheus.MustNewConstMetric(
-			mcuWriteBytes,
-			prometheus.GaugeValue,
-			mv.LastStats.BytesWrite,
-			sensorName)
-		ch <- prometheus.MustNewConstMetric(
-			mcuReadBytes,
-			prometheus.GaugeValue,
-			mv.LastStats.BytesRead,
-			sensorName)
-		ch <- prometheus.MustNewConstMetric(
-			mcuRetransmitBytes,
-			prometheus.GaugeValue,
-			mv.LastStats.BytesRetransmit,
-			sensorName)
-		ch <- prometheus.MustNewConstMetric(
-			mcuInvalidBytes,
-			prometheus.GaugeValue,
-			mv.LastStats.BytesInvalid,
-			sensorName)
-		ch <- prometheus.MustNewConstMetric(
-			mcuSendSeq,
-			prometheus.GaugeValue,
-			mv.LastStats.SendSeq,
-			sensorName)
-		ch <- prometheus.MustNewConstMetric(
-			mcuReceiveSeq,
-			prometheus.GaugeValue,
-			mv.LastStats.ReceiveSeq,
-			sensorName)
-		ch <- prometheus.MustNewConstMetric(
-			mcuRetransmitSeq,
-			prometheus.GaugeValue,
-			mv.LastStats.RetransmitSeq,
-			sensorName)
-		ch <- prometheus.MustNewConstMetric(
-			mcuSrtt,
-			prometheus.GaugeValue,
-			mv.LastStats.Srtt,
-			sensorName)
-		ch <- prometheus.MustNewConstMetric(
-			mcuRttvar,
-			prometheus.GaugeValue,
-			mv.LastStats.Rttvar,
-			sensorName)
-		ch <- prometheus.MustNewConstMetric(
-			mcuRto,
-			prometheus.GaugeValue,
-			mv.LastStats.Rto,
-			sensorName)
-		ch <- prometheus.MustNewConstMetric(
-			mcuReadyBytes,
-			prometheus.GaugeValue,
-			mv.LastStats.ReadyBytes,
-			sensorName)
-		ch <- prometheus.MustNewConstMetric(
-			mcuStalledBytes,
-			prometheus.GaugeValue,
-			mv.LastStats.StalledBytes,
-			sensorName)
-		ch <- prometheus.MustNewConstMetric(
-			mcuClockFrequency,
-			prometheus.GaugeValue,
-			mv.LastStats.Freq,
-			sensorName)
+		sensorName := c.labelName(mk)
+		mcuAwakeDesc.emit(ch, mv.LastStats.McuAwake, sensorName)
+		mcuTaskAvgDesc.emit(ch, mv.LastStats.McuTaskAvg, sensorName)
+		mcuTaskStddevDesc.emit(ch, mv.LastStats.McuTaskStddev, sensorName)
+		mcuWriteBytesDesc.emit(ch, mv.LastStats.BytesWrite, sensorName)
+		mcuReadBytesDesc.emit(ch, mv.LastStats.BytesRead, sensorName)
+		mcuRetransmitBytesDesc.emit(ch, mv.LastStats.BytesRetransmit, sensorName)
+		mcuInvalidBytesDesc.emit(ch, mv.LastStats.BytesInvalid, sensorName)
+		mcuSendSeqDesc.emit(ch, mv.LastStats.SendSeq, sensorName)
+		mcuReceiveSeqDesc.emit(ch, mv.LastStats.ReceiveSeq, sensorName)
+		mcuRetransmitSeqDesc.emit(ch, mv.LastStats.RetransmitSeq, sensorName)
+		mcuSrttDesc.emit(ch, mv.LastStats.Srtt, sensorName)
+		mcuRttvarDesc.emit(ch, mv.LastStats.Rttvar, sensorName)
+		mcuRtoDesc.emit(ch, mv.LastStats.Rto, sensorName)
+		mcuReadyBytesDesc.emit(ch, mv.LastStats.ReadyBytes, sensorName)
+		mcuStalledBytesDesc.emit(ch, mv.LastStats.StalledBytes, sensorName)
+		mcuClockFrequencyDesc.emit(ch, mv.LastStats.Freq, sensorName)
 	}
 
 	// toolhead
-	c.emitGauge(ch, "klipper_toolhead_print_time", "Klipper toolhead print time.", result.Result.Status.Toolhead.PrintTime)
-	c.emitGauge(ch, "klipper_toolhead_estimated_print_time", "Klipper estimated print time.", result.Result.Status.Toolhead.EstimatedPrintTime)
-	c.emitGauge(ch, "klipper_toolhead_max_velocity", "Klipper toolhead max velocity.", result.Result.Status.Toolhead.MaxVelocity)
-	c.emitGauge(ch, "klipper_toolhead_max_accel", "Klipper toolhead max acceleration.", result.Result.Status.Toolhead.MaxAccel)
-	c.emitGauge(ch, "klipper_toolhead_max_accel_to_decel", "Klipper toolhead max acceleration to deceleration.", result.Result.Status.Toolhead.MaxAccelToDecel)
-	c.emitGauge(ch, "klipper_toolhead_square_corner_velocity", "Klipper toolhead square corner velocity.", result.Result.Status.Toolhead.SquareCornerVelocity)
+	toolheadPrintTimeDesc.emit(ch, result.Result.Status.Toolhead.PrintTime)
+	toolheadEstimatedPrintTimeDesc.emit(ch, result.Result.Status.Toolhead.EstimatedPrintTime)
+	toolheadMaxVelocityDesc.emit(ch, result.Result.Status.Toolhead.MaxVelocity)
+	toolheadMaxAccelDesc.emit(ch, result.Result.Status.Toolhead.MaxAccel)
+	toolheadMaxAccelToDecelDesc.emit(ch, result.Result.Status.Toolhead.MaxAccelToDecel)
+	toolheadSquareCornerVelocityDesc.emit(ch, result.Result.Status.Toolhead.SquareCornerVelocity)
 
 	// toolhead homed axes
 	for _, axis := range result.Result.Status.Toolhead.HomedAxes {
-		emitStateInfoMetric(ch, "klipper_toolhead_homed_axes_info", "A homed axis on the toolhead.", "axis", string(axis))
+		toolheadHomedAxesInfoDesc.emitInfo(ch, string(axis))
 	}
-	c.emitCounter(ch, "klipper_toolhead_stalls_total", "Total number of toolhead stalls.", result.Result.Status.Toolhead.Stalls)
+	toolheadStallsTotalDesc.emit(ch, result.Result.Status.Toolhead.Stalls)
 
 	// extruder
-	c.emitGauge(ch, "klipper_extruder_temperature", "Klipper extruder temperature.", result.Result.Status.Extruder.Temperature)
-	c.emitGauge(ch, "klipper_extruder_target", "Klipper extruder target.", result.Result.Status.Extruder.Target)
-	c.emitGauge(ch, "klipper_extruder_power", "Klipper extruder power.", result.Result.Status.Extruder.Power)
-	c.emitGauge(ch, "klipper_extruder_pressure_advance", "Klipper extruder pressure advance.", result.Result.Status.Extruder.PressureAdvance)
-	c.emitGauge(ch, "klipper_extruder_smooth_time", "Klipper extruder smooth time.", result.Result.Status.Extruder.SmoothTime)
+	extruderTemperatureDesc.emit(ch, result.Result.Status.Extruder.Temperature)
+	extruderTargetDesc.emit(ch, result.Result.Status.Extruder.Target)
+	extruderPowerDesc.emit(ch, result.Result.Status.Extruder.Power)
+	extruderPressureAdvanceDesc.emit(ch, result.Result.Status.Extruder.PressureAdvance)
+	extruderSmoothTimeDesc.emit(ch, result.Result.Status.Extruder.SmoothTime)
 
 	// heater_bed
-	c.emitGauge(ch, "klipper_heater_bed_temperature", "Klipper heater bed temperature.", result.Result.Status.HeaterBed.Temperature)
-	c.emitGauge(ch, "klipper_heater_bed_target", "Klipper heater bed target.", result.Result.Status.HeaterBed.Target)
-	c.emitGauge(ch, "klipper_heater_bed_power", "Klipper heater bed power.", result.Result.Status.HeaterBed.Power)
+	heaterBedTemperatureDesc.emit(ch, result.Result.Status.HeaterBed.Temperature)
+	heaterBedTargetDesc.emit(ch, result.Result.Status.HeaterBed.Target)
+	heaterBedPowerDesc.emit(ch, result.Result.Status.HeaterBed.Power)
 
 	// fan
-	c.emitGauge(ch, "klipper_fan_speed", "Klipper fan speed.", result.Result.Status.Fan.Speed)
+	fanSpeedDesc.emit(ch, result.Result.Status.Fan.Speed)
 	if result.Result.Status.Fan.Rpm != nil {
-		c.emitGauge(ch, "klipper_fan_rpm", "Klipper fan rpm.", *result.Result.Status.Fan.Rpm)
+		fanRpmDesc.emit(ch, *result.Result.Status.Fan.Rpm)
 	}
 
 	// idle_timeout
-	c.emitCounter(ch, "klipper_printing_time", "The amount of time the printer has been in the Printing state.", result.Result.Status.IdleTimeout.PrintingTime)
-	emitStateInfoMetric(ch, "klipper_idle_timeout_state_info", "The current idle timeout state of the printer.", "state", result.Result.Status.IdleTimeout.State)
+	printingTimeDesc.emit(ch, result.Result.Status.IdleTimeout.PrintingTime)
+	idleTimeoutStateInfoDesc.emitInfo(ch, result.Result.Status.IdleTimeout.State)
 
 	// virtual_sdcard
-	c.emitCounter(ch, "klipper_print_file_progress", "The print progress reported as a percentage of the file read.", result.Result.Status.VirtualSdCard.Progress)
-	c.emitCounter(ch, "klipper_print_file_position", "The current file position in bytes.", result.Result.Status.VirtualSdCard.FilePosition)
-	c.emitGauge(ch, "klipper_sdcard_active", "Indicates whether the virtual SD card is actively being read (1) or not (0).", boolToFloat64(result.Result.Status.VirtualSdCard.IsActive))
+	printFileProgressDesc.emit(ch, result.Result.Status.VirtualSdCard.Progress)
+	printFilePositionDesc.emit(ch, result.Result.Status.VirtualSdCard.FilePosition)
+	sdcardActiveDesc.emit(ch, boolToFloat64(result.Result.Status.VirtualSdCard.IsActive))
 
 	// print_stats
-	c.emitCounter(ch, "klipper_print_total_duration", "The total time (in seconds) elapsed since a print has started.", result.Result.Status.PrintStats.TotalDuration)
-	c.emitCounter(ch, "klipper_print_print_duration", "The total time spent printing (in seconds).", result.Result.Status.PrintStats.PrintDuration)
-	c.emitCounter(ch, "klipper_print_filament_used", "The amount of filament used during the current print (in mm)..", result.Result.Status.PrintStats.FilamentUsed)
+	printTotalDurationDesc.emit(ch, result.Result.Status.PrintStats.TotalDuration)
+	printPrintDurationDesc.emit(ch, result.Result.Status.PrintStats.PrintDuration)
+	printFilamentUsedDesc.emit(ch, result.Result.Status.PrintStats.FilamentUsed)
 
 	// print state
-	emitStateInfoMetric(ch, "klipper_print_state_info", "The current print state of the printer.", "state", result.Result.Status.PrintStats.State)
+	printStateInfoDesc.emitInfo(ch, result.Result.Status.PrintStats.State)
 	if result.Result.Status.PrintStats.State != "" {
-		c.emitGauge(ch, "klipper_printing", "Indicates whether the printer is currently printing (1) or not (0).", boolToFloat64(result.Result.Status.PrintStats.State == "printing"))
+		printingDesc.emit(ch, boolToFloat64(result.Result.Status.PrintStats.State == "printing"))
 	}
 
 	// webhooks
-	emitStateInfoMetric(ch, "klipper_webhooks_state_info", "The current state of the Klipper webhooks server.", "state", result.Result.Status.Webhooks.State)
+	webhooksStateInfoDesc.emitInfo(ch, result.Result.Status.Webhooks.State)
 
 	// pause_resume
-	c.emitGauge(ch, "klipper_pause_resume_is_paused", "Indicates whether the print is paused (1) or not (0).", boolToFloat64(result.Result.Status.PauseResume.IsPaused))
+	pauseResumeIsPausedDesc.emit(ch, boolToFloat64(result.Result.Status.PauseResume.IsPaused))
 
 	// display_status
-	c.emitCounter(ch, "klipper_print_gcode_progress", "The percentage of print progress, as reported by M73.", result.Result.Status.DisplayStatus.Progress)
+	printGcodeProgressDesc.emit(ch, result.Result.Status.DisplayStatus.Progress)
 
 	// temperature_sensor
-	temperatureSensorLabels := []string{"sensor"}
-	temperatureSensor := prometheus.NewDesc("klipper_temperature_sensor_temperature", "The temperature of the temperature sensor", temperatureSensorLabels, nil)
-	temperatureSensorMinTemp := prometheus.NewDesc("klipper_temperature_sensor_measured_min_temp", "The measured minimum temperature of the temperature sensor", temperatureSensorLabels, nil)
-	temperatureSensorMaxTemp := prometheus.NewDesc("klipper_temperature_sensor_measured_max_temp", "The measured maximum temperature of the temperature sensor", temperatureSensorLabels, nil)
 	for sk, sv := range result.Result.Status.TemperatureSensors {
-		sensorName := GetValidLabelName(sk)
-		ch <- prometheus.MustNewConstMetric(
-			temperatureSensor,
-			prometheus.GaugeValue,
-			sv.Temperature,
-			sensorName)
-		ch <- prometheus.MustNewConstMetric(
-			temperatureSensorMinTemp,
-			prometheus.GaugeValue,
-			sv.MeasuredMinTemp,
-			sensorName)
-		ch <- prometheus.MustNewConstMetric(
-			temperatureSensorMaxTemp,
-			prometheus.GaugeValue,
-			sv.MeasuredMaxTemp,
-			sensorName)
+		sensorName := c.labelName(sk)
+		temperatureSensorTemperatureDesc.emit(ch, sv.Temperature, sensorName)
+		temperatureSensorMeasuredMinTempDesc.emit(ch, sv.MeasuredMinTemp, sensorName)
+		temperatureSensorMeasuredMaxTempDesc.emit(ch, sv.MeasuredMaxTemp, sensorName)
 	}
 
 	// temperature_fan
-	fanLabels := []string{"fan"}
-	fanSpeed := prometheus.NewDesc("klipper_temperature_fan_speed", "The speed of the temperature fan", fanLabels, nil)
-	fanTemperature := prometheus.NewDesc("klipper_temperature_fan_temperature", "The temperature of the temperature fan", fanLabels, nil)
-	fanTarget := prometheus.NewDesc("klipper_temperature_fan_target", "The target temperature for the temperature fan", fanLabels, nil)
-	fanRpm := prometheus.NewDesc("klipper_temperature_fan_rpm", "The RPM of the temperature fan", fanLabels, nil)
 	for fk, fv := range result.Result.Status.TemperatureFans {
-		fanName := GetValidLabelName(fk)
-		ch <- prometheus.MustNewConstMetric(
-			fanSpeed,
-			prometheus.GaugeValue,
-			fv.Speed,
-			fanName)
-		ch <- prometheus.MustNewConstMetric(
-			fanTemperature,
-			prometheus.GaugeValue,
-			fv.Temperature,
-			fanName)
-		ch <- prometheus.MustNewConstMetric(
-			fanTarget,
-			prometheus.GaugeValue,
-			fv.Target,
-			fanName)
+		fanName := c.labelName(fk)
+		temperatureFanSpeedDesc.emit(ch, fv.Speed, fanName)
+		temperatureFanTemperatureDesc.emit(ch, fv.Temperature, fanName)
+		temperatureFanTargetDesc.emit(ch, fv.Target, fanName)
 		if fv.Rpm != nil {
-			ch <- prometheus.MustNewConstMetric(
-				fanRpm,
-				prometheus.GaugeValue,
-				*fv.Rpm,
-				fanName)
+			temperatureFanRpmDesc.emit(ch, *fv.Rpm, fanName)
 		}
 	}
 
 	// temperature_probe
-	temperatureProbeLabels := []string{"sensor"}
-	temperatureProbe := prometheus.NewDesc("klipper_temperature_probe_temperature", "The temperature of the temperature probe", temperatureProbeLabels, nil)
-	temperatureProbeMinTemp := prometheus.NewDesc("klipper_temperature_probe_measured_min_temp", "The measured minimum temperature of the temperature probe", temperatureProbeLabels, nil)
-	temperatureProbeMaxTemp := prometheus.NewDesc("klipper_temperature_probe_measured_max_temp", "The measured maximum temperature of the temperature probe", temperatureProbeLabels, nil)
-	temperatureProbeEstimatedExpansion := prometheus.NewDesc("klipper_temperature_probe_estimated_expansion", "The estimated of the temperature probe", temperatureProbeLabels, nil)
 	for sk, sv := range result.Result.Status.TemperatureProbes {
-		probeName := GetValidLabelName(sk)
-		ch <- prometheus.MustNewConstMetric(
-			temperatureProbe,
-			prometheus.GaugeValue,
-			sv.Temperature,
-			probeName)
-		ch <- prometheus.MustNewConstMetric(
-			temperatureProbeMinTemp,
-			prometheus.GaugeValue,
-			sv.MeasuredMinTemp,
-			probeName)
-		ch <- prometheus.MustNewConstMetric(
-			temperatureProbeMaxTemp,
-			prometheus.GaugeValue,
-			sv.MeasuredMaxTemp,
-			probeName)
-		ch <- prometheus.MustNewConstMetric(
-			temperatureProbeEstimatedExpansion,
-			prometheus.GaugeValue,
-			sv.EstimatedExpansion,
-			probeName)
+		probeName := c.labelName(sk)
+		temperatureProbeTemperatureDesc.emit(ch, sv.Temperature, probeName)
+		temperatureProbeMeasuredMinTempDesc.emit(ch, sv.MeasuredMinTemp, probeName)
+		temperatureProbeMeasuredMaxTempDesc.emit(ch, sv.MeasuredMaxTemp, probeName)
+		temperatureProbeEstimatedExpansionDesc.emit(ch, sv.EstimatedExpansion, probeName)
 	}
 
 	// output_pin
-	pinLabels := []string{"pin"}
-	pinValue := prometheus.NewDesc("klipper_output_pin_value", "The value of the output pin", pinLabels, nil)
 	for k, v := range result.Result.Status.OutputPins {
-		pinName := GetValidLabelName(k)
-		ch <- prometheus.MustNewConstMetric(
-			pinValue,
-			prometheus.GaugeValue,
-			v.Value,
-			pinName)
+		pinName := c.labelName(k)
+		outputPinValueDesc.emit(ch, v.Value, pinName)
 	}
 
 	// fan_generic
-	genericFanLabels := []string{"fan"}
-	genericFanSpeed := prometheus.NewDesc("klipper_generic_fan_speed", "The speed of the generic fan", genericFanLabels, nil)
-	genericFanRpm := prometheus.NewDesc("klipper_generic_fan_rpm", "The RPM of the generic fan", genericFanLabels, nil)
 	for fk, fv := range result.Result.Status.GenericFans {
-		fanName := GetValidLabelName(fk)
-		ch <- prometheus.MustNewConstMetric(
-			genericFanSpeed,
-			prometheus.GaugeValue,
-			fv.Speed,
-			fanName)
+		fanName := c.labelName(fk)
+		genericFanSpeedDesc.emit(ch, fv.Speed, fanName)
 		if fv.Rpm != nil {
-			ch <- prometheus.MustNewConstMetric(
-				genericFanRpm,
-				prometheus.GaugeValue,
-				*fv.Rpm,
-				fanName)
+			genericFanRpmDesc.emit(ch, *fv.Rpm, fanName)
 		}
 	}
 
 	// controller_fan
-	controllerFanLabels := []string{"fan"}
-	controllerFanSpeed := prometheus.NewDesc("klipper_controller_fan_speed", "The speed of the controller fan", controllerFanLabels, nil)
-	controllerFanRpm := prometheus.NewDesc("klipper_controller_fan_rpm", "The RPM of the controller fan", controllerFanLabels, nil)
 	for fk, fv := range result.Result.Status.ControllerFans {
-		fanName := GetValidLabelName(fk)
-		ch <- prometheus.MustNewConstMetric(
-			controllerFanSpeed,
-			prometheus.GaugeValue,
-			fv.Speed,
-			fanName)
+		fanName := c.labelName(fk)
+		controllerFanSpeedDesc.emit(ch, fv.Speed, fanName)
 		if fv.Rpm != nil {
-			ch <- prometheus.MustNewConstMetric(
-				controllerFanRpm,
-				prometheus.GaugeValue,
-				*fv.Rpm,
-				fanName)
+			controllerFanRpmDesc.emit(ch, *fv.Rpm, fanName)
 		}
 	}
 
 	// heater_fan
-	heaterFanLabels := []string{"fan"}
-	heaterFanSpeed := prometheus.NewDesc("klipper_heater_fan_speed", "The speed of the heater fan", heaterFanLabels, nil)
-	heaterFanRpm := prometheus.NewDesc("klipper_heater_fan_rpm", "The RPM of the heater fan", heaterFanLabels, nil)
 	for fk, fv := range result.Result.Status.HeaterFans {
-		fanName := GetValidLabelName(fk)
-		ch <- prometheus.MustNewConstMetric(
-			heaterFanSpeed,
-			prometheus.GaugeValue,
-			fv.Speed,
-			fanName)
+		fanName := c.labelName(fk)
+		heaterFanSpeedDesc.emit(ch, fv.Speed, fanName)
 		if fv.Rpm != nil {
-			ch <- prometheus.MustNewConstMetric(
-				heaterFanRpm,
-				prometheus.GaugeValue,
-				*fv.Rpm,
-				fanName)
+			heaterFanRpmDesc.emit(ch, *fv.Rpm, fanName)
 		}
 	}
 
 	// filament_*_sensor
-	filamentSensorLabels := []string{"sensor"}
-	filamentSensorDetected := prometheus.NewDesc("klipper_filament_sensor_detected", "Whether filament presence is detected by the sensor", filamentSensorLabels, nil)
-	filamentSensorEnabled := prometheus.NewDesc("klipper_filament_sensor_enabled", "Whether the filament sensor is enabled or not", filamentSensorLabels, nil)
 	for k, v := range result.Result.Status.FilamentSensors {
-		sensorName := GetValidLabelName(k)
-		ch <- prometheus.MustNewConstMetric(
-			filamentSensorDetected,
-			prometheus.GaugeValue,
-			boolToFloat64(v.Detected),
-			sensorName)
-		ch <- prometheus.MustNewConstMetric(
-			filamentSensorEnabled,
-			prometheus.GaugeValue,
-			boolToFloat64(v.Enabled),
-			sensorName)
+		sensorName := c.labelName(k)
+		filamentSensorDetectedDesc.emit(ch, boolToFloat64(v.Detected), sensorName)
+		filamentSensorEnabledDesc.emit(ch, boolToFloat64(v.Enabled), sensorName)
 	}
 
 	// heater_generic
-	genericHeaterLabels := []string{"heater"}
-	genericHeaterTemperature := prometheus.NewDesc("klipper_generic_heater_temperature", "The temperature of the generic heater", genericHeaterLabels, nil)
-	genericHeaterTarget := prometheus.NewDesc("klipper_generic_heater_target", "The target temperature of the generic heater", genericHeaterLabels, nil)
-	genericHeaterPower := prometheus.NewDesc("klipper_generic_heater_power", "The output power of the generic heater", genericHeaterLabels, nil)
 	for name, heater := range result.Result.Status.GenericHeaters {
-		heaterName := GetValidLabelName(name)
-		ch <- prometheus.MustNewConstMetric(
-			genericHeaterTemperature,
-			prometheus.GaugeValue,
-			heater.Temperature,
-			heaterName)
-		ch <- prometheus.MustNewConstMetric(
-			genericHeaterTarget,
-			prometheus.GaugeValue,
-			heater.Target,
-			heaterName)
-		ch <- prometheus.MustNewConstMetric(
-			genericHeaterPower,
-			prometheus.GaugeValue,
-			heater.Power,
-			heaterName)
+		heaterName := c.labelName(name)
+		genericHeaterTemperatureDesc.emit(ch, heater.Temperature, heaterName)
+		genericHeaterTargetDesc.emit(ch, heater.Target, heaterName)
+		genericHeaterPowerDesc.emit(ch, heater.Power, heaterName)
 	}
 
 	// tmc sensors
-	tmcSensorLabels := []string{"sensor"}
-	tmcTemperatureSensor := prometheus.NewDesc("klipper_tmc_sensor_temperature", "The temperature of the tmc driver", tmcSensorLabels, nil)
-	tmcRunCurrentSensor := prometheus.NewDesc("klipper_tmc_sensor_run_current", "The run current of the tmc driver", tmcSensorLabels, nil)
-	tmcEnabledSensor := prometheus.NewDesc("klipper_tmc_sensor_enabled", "Whether the tmc driver is enabled or not", tmcSensorLabels, nil)
-
 	for sk, sv := range result.Result.Status.TmcSensors {
-		sensorName := GetValidLabelName(strings.ReplaceAll(sk, " ", "_"))
+		sensorName := c.labelName(strings.ReplaceAll(sk, " ", "_"))
 		if sv.Temperature != nil {
-			ch <- prometheus.MustNewConstMetric(
-				tmcTemperatureSensor,
-				prometheus.GaugeValue,
-				*sv.Temperature,
-				sensorName)
+			tmcSensorTemperatureDesc.emit(ch, *sv.Temperature, sensorName)
 		}
-		ch <- prometheus.MustNewConstMetric(
-			tmcRunCurrentSensor,
-			prometheus.GaugeValue,
-			sv.RunCurrent,
-			sensorName)
+		tmcSensorRunCurrentDesc.emit(ch, sv.RunCurrent, sensorName)
 
-		ch <- prometheus.MustNewConstMetric(
-			tmcEnabledSensor,
-			prometheus.GaugeValue,
-			boolToFloat64(sv.DrvStatus != nil),
-			sensorName)
+		tmcSensorEnabledDesc.emit(ch, boolToFloat64(sv.DrvStatus != nil), sensorName)
 	}
 
 	// input_shaper
-	c.emitGauge(ch, "klipper_input_shaper_frequency_x", "Input shaper frequency for X axis.", result.Result.Status.InputShaper.FrequencyX)
-	c.emitGauge(ch, "klipper_input_shaper_frequency_y", "Input shaper frequency for Y axis.", result.Result.Status.InputShaper.FrequencyY)
-	c.emitGauge(ch, "klipper_input_shaper_damping_ratio_x", "Input shaper damping ratio for X axis.", result.Result.Status.InputShaper.DampingRatioX)
-	c.emitGauge(ch, "klipper_input_shaper_damping_ratio_y", "Input shaper damping ratio for Y axis.", result.Result.Status.InputShaper.DampingRatioY)
+	inputShaperFrequencyXDesc.emit(ch, result.Result.Status.InputShaper.FrequencyX)
+	inputShaperFrequencyYDesc.emit(ch, result.Result.Status.InputShaper.FrequencyY)
+	inputShaperDampingRatioXDesc.emit(ch, result.Result.Status.InputShaper.DampingRatioX)
+	inputShaperDampingRatioYDesc.emit(ch, result.Result.Status.InputShaper.DampingRatioY)
 
 	// input_shaper type (info-style metric with axis + type labels)
-	inputShaperTypeLabels := []string{"axis", "type"}
-	inputShaperTypeDesc := prometheus.NewDesc("klipper_input_shaper_type_info", "Input shaper type per axis.", inputShaperTypeLabels, nil)
 	if result.Result.Status.InputShaper.ShaperTypeX != "" {
-		ch <- prometheus.MustNewConstMetric(inputShaperTypeDesc, prometheus.GaugeValue, 1, "x", result.Result.Status.InputShaper.ShaperTypeX)
+		inputShaperTypeInfoDesc.emit(ch, 1, "x", result.Result.Status.InputShaper.ShaperTypeX)
 	}
 	if result.Result.Status.InputShaper.ShaperTypeY != "" {
-		ch <- prometheus.MustNewConstMetric(inputShaperTypeDesc, prometheus.GaugeValue, 1, "y", result.Result.Status.InputShaper.ShaperTypeY)
+		inputShaperTypeInfoDesc.emit(ch, 1, "y", result.Result.Status.InputShaper.ShaperTypeY)
 	}
 
 	// firmware_retraction
-	c.emitGauge(ch, "klipper_firmware_retract_length", "Firmware retraction length in mm.", result.Result.Status.FirmwareRetraction.RetractLength)
-	c.emitGauge(ch, "klipper_firmware_retract_speed", "Firmware retraction speed in mm/min.", result.Result.Status.FirmwareRetraction.RetractSpeed)
-	c.emitGauge(ch, "klipper_firmware_unretract_extra_length", "Firmware unretract extra length in mm.", result.Result.Status.FirmwareRetraction.UnretractExtraLength)
-	c.emitGauge(ch, "klipper_firmware_unretract_speed", "Firmware unretract speed in mm/min.", result.Result.Status.FirmwareRetraction.UnretractSpeed)
+	firmwareRetractLengthDesc.emit(ch, result.Result.Status.FirmwareRetraction.RetractLength)
+	firmwareRetractSpeedDesc.emit(ch, result.Result.Status.FirmwareRetraction.RetractSpeed)
+	firmwareUnretractExtraLengthDesc.emit(ch, result.Result.Status.FirmwareRetraction.UnretractExtraLength)
+	firmwareUnretractSpeedDesc.emit(ch, result.Result.Status.FirmwareRetraction.UnretractSpeed)
+	return nil
 }
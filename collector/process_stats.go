@@ -4,158 +4,105 @@ package collector
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
-	"golang.org/x/exp/slices"
 )
 
-type MoonrakerProcessStatsQueryResponse struct {
-	Result struct {
-		MoonrakerStats       []MoonrakerProcStats             `json:"moonraker_stats"`
-		CpuTemp              float64                          `json:"cpu_temp"`
-		Network              map[string]MoonrakerNetworkStats `json:"network"`
-		SystemCpuUsage       MoonrakerSystemCpuUsage          `json:"system_cpu_usage"`
-		SystemMemory         MoonrakerSystemMemory            `json:"system_memory"`
-		SystemUptime         float64                          `json:"system_uptime"`
-		WebsocketConnections int                              `json:"websocket_connectsions"`
-		ThrottledState       MoonrakerThrottledState          `json:"throttled_state"`
-	} `json:"result"`
-}
-
-type MoonrakerProcStats struct {
-	Time     float64 `json:"time"`
-	CpuUsage float64 `json:"cpu_usage"`
-	Memory   int     `json:"memory"`
-	MemUnits string  `json:"mem_units"`
-}
-
-type MoonrakerNetworkStats struct {
-	RxBytes   int64   `json:"rx_bytes"`
-	TxBytes   int64   `json:"tx_bytes"`
-	RxPackets int64   `json:"rx_packets"`
-	TxPackets int64   `json:"tx_packets"`
-	RxErrs    int     `json:"rx_errs"`
-	TxErrs    int     `json:"tx_errs"`
-	RxDrop    int     `json:"rx_drop"`
-	TxDrop    int     `json:"tx_drop"`
-	Bandwidth float64 `json:"bandwidth"`
-}
-
-type MoonrakerSystemCpuUsage struct {
-	Cpu  float64 `json:"cpu"`
-	Cpu0 float64 `json:"cpu0"`
-	Cpu1 float64 `json:"cpu1"`
-	Cpu2 float64 `json:"cpu2"`
-	Cpu3 float64 `json:"cpu3"`
-}
+// Metrics of the process_stats module
+var (
+	moonrakerMemoryKbDesc             = newGauge("process_stats", "klipper_moonraker_memory_kb", "Moonraker memory usage in Kb.")
+	moonrakerCpuUsageDesc             = newGauge("process_stats", "klipper_moonraker_cpu_usage", "Moonraker CPU usage.")
+	moonrakerWebsocketConnectionsDesc = newGauge("process_stats", "klipper_moonraker_websocket_connections", "Moonraker Websocket connection count.")
+	systemCpuTempDesc                 = newGauge("process_stats", "klipper_system_cpu_temp", "Klipper system CPU temperature in celsius.")
+	systemCpuDesc                     = newGauge("process_stats", "klipper_system_cpu", "Klipper system CPU usage.")
+	systemMemoryTotalDesc             = newGauge("process_stats", "klipper_system_memory_total", "Klipper system total memory.")
+	systemMemoryAvailableDesc         = newGauge("process_stats", "klipper_system_memory_available", "Klipper system available memory.")
+	systemMemoryUsedDesc              = newGauge("process_stats", "klipper_system_memory_used", "Klipper system used memory.")
+	systemUptimeDesc                  = newCounter("process_stats", "klipper_system_uptime", "Klipper system uptime.")
+	systemThrottledBitsDesc           = newGauge("process_stats", "klipper_system_throttled_bits", "Klipper system throttled state bitmask.")
+	systemThrottledFlagInfoDesc       = newGauge("process_stats", "klipper_system_throttled_flag_info", "Klipper system throttled state flag.", "flag")
+)
 
-type MoonrakerSystemMemory struct {
-	Total     int `json:"total"`
-	Available int `json:"available"`
-	Used      int `json:"used"`
-}
+// Metrics of the network_stats module
+var (
+	networkRxBytesDesc   = newCounter("network_stats", "klipper_network_rx_bytes", "Klipper network received bytes.", "interface")
+	networkTxBytesDesc   = newCounter("network_stats", "klipper_network_tx_bytes", "Klipper network transmitted bytes.", "interface")
+	networkRxPacketsDesc = newCounter("network_stats", "klipper_network_rx_packets", "Klipper network received packets.", "interface")
+	networkTxPacketsDesc = newCounter("network_stats", "klipper_network_tx_packets", "Klipper network transmitted packets.", "interface")
+	networkRxErrsDesc    = newCounter("network_stats", "klipper_network_rx_errs", "Klipper network received errored packets.", "interface")
+	networkTxErrsDesc    = newCounter("network_stats", "klipper_network_tx_errs", "Klipper network transmitted errored packets.", "interface")
+	networkRxDropDesc    = newCounter("network_stats", "klipper_network_rx_drop", "Klipper network received dropped packets.", "interface")
+	networkTxDropDesc    = newCounter("network_stats", "klipper_network_tx_drop", "Klipper network transmitted dropped packets.", "interface")
+	networkBandwidthDesc = newGauge("network_stats", "klipper_network_bandwidth", "Klipper network bandwidth.", "interface")
+)
 
-type MoonrakerThrottledState struct {
-	Bits  float64  `json:"bits"`
-	Flags []string `json:"flags"`
+func init() {
+	RegisterModule(builtinModule{
+		name:           "process_stats",
+		defaultEnabled: true,
+		endpoints:      []string{"/machine/proc_stats"},
+		collect:        Collector.collectProcessStats,
+	})
+	RegisterModule(builtinModule{
+		name:           "network_stats",
+		defaultEnabled: false,
+		endpoints:      []string{"/machine/proc_stats"},
+		collect:        Collector.collectNetworkStats,
+	})
 }
 
-func (c Collector) collectProcessAndNetworkStats(ch chan<- prometheus.Metric) bool {
-	var result MoonrakerProcessStatsQueryResponse
-	if err := c.fetchFromMoonraker("/machine/proc_stats", &result); err != nil {
-		log.Error(err)
-		return true
+func (c Collector) collectProcessStats(ch chan<- prometheus.Metric) error {
+	stats, err := c.Client().ProcStats(c.ctx)
+	if err != nil {
+		return err
 	}
 
-	// Process Stats
-	if slices.Contains(c.modules, "process_stats") {
-		moonrakerStatsCount := len(result.Result.MoonrakerStats)
-		if moonrakerStatsCount == 0 {
-			log.Warn("Empty moonraker_stats in Process Stats response, skipping Memory and CPU usage stats")
+	moonrakerStatsCount := len(stats.MoonrakerStats)
+	if moonrakerStatsCount == 0 {
+		c.logger.Warn("Empty moonraker_stats in Process Stats response, skipping Memory and CPU usage stats")
+	} else {
+		memUnits := stats.MoonrakerStats[moonrakerStatsCount-1].MemUnits
+		if memUnits != "kB" {
+			c.logger.Errorf("Unexpected units %s for Moonraker memory usage", memUnits)
 		} else {
-			memUnits := result.Result.MoonrakerStats[moonrakerStatsCount-1].MemUnits
-			if memUnits != "kB" {
-				log.Errorf("Unexpected units %s for Moonraker memory usage", memUnits)
-			} else {
-				c.emitGauge(ch, "klipper_moonraker_memory_kb", "Moonraker memory usage in Kb.", float64(result.Result.MoonrakerStats[moonrakerStatsCount-1].Memory))
-			}
-
-			c.emitGauge(ch, "klipper_moonraker_cpu_usage", "Moonraker CPU usage.", result.Result.MoonrakerStats[moonrakerStatsCount-1].CpuUsage)
+			moonrakerMemoryKbDesc.emit(ch, float64(stats.MoonrakerStats[moonrakerStatsCount-1].Memory))
 		}
 
-		c.emitGauge(ch, "klipper_moonraker_websocket_connections", "Moonraker Websocket connection count.", float64(result.Result.WebsocketConnections))
-		c.emitGauge(ch, "klipper_system_cpu_temp", "Klipper system CPU temperature in celsius.", result.Result.CpuTemp)
-		c.emitGauge(ch, "klipper_system_cpu", "Klipper system CPU usage.", result.Result.SystemCpuUsage.Cpu)
-		c.emitGauge(ch, "klipper_system_memory_total", "Klipper system total memory.", float64(result.Result.SystemMemory.Total))
-		c.emitGauge(ch, "klipper_system_memory_available", "Klipper system available memory.", float64(result.Result.SystemMemory.Available))
-		c.emitGauge(ch, "klipper_system_memory_used", "Klipper system used memory.", float64(result.Result.SystemMemory.Used))
-		c.emitCounter(ch, "klipper_system_uptime", "Klipper system uptime.", result.Result.SystemUptime)
+		moonrakerCpuUsageDesc.emit(ch, stats.MoonrakerStats[moonrakerStatsCount-1].CpuUsage)
+	}
 
-		c.emitGauge(ch, "klipper_system_throttled_bits", "Klipper system throttled state bitmask.", result.Result.ThrottledState.Bits)
-		for _, flag := range result.Result.ThrottledState.Flags {
-			emitStateInfoMetric(ch, "klipper_system_throttled_flag_info", "Klipper system throttled state flag.", "flag", flag)
-		}
+	moonrakerWebsocketConnectionsDesc.emit(ch, float64(stats.WebsocketConnections))
+	systemCpuTempDesc.emit(ch, stats.CpuTemp)
+	systemCpuDesc.emit(ch, stats.SystemCpuUsage.Cpu)
+	systemMemoryTotalDesc.emit(ch, float64(stats.SystemMemory.Total))
+	systemMemoryAvailableDesc.emit(ch, float64(stats.SystemMemory.Available))
+	systemMemoryUsedDesc.emit(ch, float64(stats.SystemMemory.Used))
+	systemUptimeDesc.emit(ch, stats.SystemUptime)
+
+	systemThrottledBitsDesc.emit(ch, stats.ThrottledState.Bits)
+	for _, flag := range stats.ThrottledState.Flags {
+		systemThrottledFlagInfoDesc.emitInfo(ch, flag)
 	}
+	return nil
+}
 
-	// Network Stats
-	if slices.Contains(c.modules, "network_stats") {
-		networkLabels := []string{"interface"}
-		rxBytes := prometheus.NewDesc("klipper_network_rx_bytes", "Klipper network received bytes.", networkLabels, nil)
-		txBytes := prometheus.NewDesc("klipper_network_tx_bytes", "Klipper network transmitted bytes.", networkLabels, nil)
-		rxPackets := prometheus.NewDesc("klipper_network_rx_packets", "Klipper network received packets.", networkLabels, nil)
-		txPackets := prometheus.NewDesc("klipper_network_tx_packets", "Klipper network transmitted packets.", networkLabels, nil)
-		rxErrs := prometheus.NewDesc("klipper_network_rx_errs", "Klipper network received errored packets.", networkLabels, nil)
-		txErrs := prometheus.NewDesc("klipper_network_tx_errs", "Klipper network transmitted errored packets.", networkLabels, nil)
-		rxDrop := prometheus.NewDesc("klipper_network_rx_drop", "Klipper network received dropped packets.", networkLabels, nil)
-		txDrop := prometheus.NewDesc("klipper_network_tx_drop", "Klipper network transmitted dropped packets.", networkLabels, nil)
-		bandwidth := prometheus.NewDesc("klipper_network_bandwidth", "Klipper network bandwidth.", networkLabels, nil)
-		for key, element := range result.Result.Network {
-			interfaceName := GetValidLabelName(key)
-			ch <- prometheus.MustNewConstMetric(
-				rxBytes,
-				prometheus.CounterValue,
-				float64(element.RxBytes),
-				interfaceName)
-			ch <- prometheus.MustNewConstMetric(
-				txBytes,
-				prometheus.CounterValue,
-				float64(element.TxBytes),
-				interfaceName)
-			ch <- prometheus.MustNewConstMetric(
-				rxPackets,
-				prometheus.CounterValue,
-				float64(element.RxPackets),
-				interfaceName)
-			ch <- prometheus.MustNewConstMetric(
-				txPackets,
-				prometheus.CounterValue,
-				float64(element.TxPackets),
-				interfaceName)
-			ch <- prometheus.MustNewConstMetric(
-				rxErrs,
-				prometheus.CounterValue,
-				float64(element.RxErrs),
-				interfaceName)
-			ch <- prometheus.MustNewConstMetric(
-				txErrs,
-				prometheus.CounterValue,
-				float64(element.TxErrs),
-				interfaceName)
-			ch <- prometheus.MustNewConstMetric(
-				rxDrop,
-				prometheus.CounterValue,
-				float64(element.RxDrop),
-				interfaceName)
-			ch <- prometheus.MustNewConstMetric(
-				txDrop,
-				prometheus.CounterValue,
-				float64(element.TxDrop),
-				interfaceName)
-			ch <- prometheus.MustNewConstMetric(
-				bandwidth,
-				prometheus.GaugeValue,
-				element.Bandwidth,
-				interfaceName)
-		}
+// collectNetworkStats shares the /machine/proc_stats request with the
+// process_stats module during a probe.
+func (c Collector) collectNetworkStats(ch chan<- prometheus.Metric) error {
+	stats, err := c.Client().ProcStats(c.ctx)
+	if err != nil {
+		return err
+	}
+
+	for key, element := range stats.Network {
+		interfaceName := c.labelName(key)
+		networkRxBytesDesc.emit(ch, float64(element.RxBytes), interfaceName)
+		networkTxBytesDesc.emit(ch, float64(element.TxBytes), interfaceName)
+		networkRxPacketsDesc.emit(ch, float64(element.RxPackets), interfaceName)
+		networkTxPacketsDesc.emit(ch, float64(element.TxPackets), interfaceName)
+		networkRxErrsDesc.emit(ch, float64(element.RxErrs), interfaceName)
+		networkTxErrsDesc.emit(ch, float64(element.TxErrs), interfaceName)
+		networkRxDropDesc.emit(ch, float64(element.RxDrop), interfaceName)
+		networkTxDropDesc.emit(ch, float64(element.TxDrop), interfaceName)
+		networkBandwidthDesc.emit(ch, element.Bandwidth, interfaceName)
 	}
-	return false
+	return nil
 }
@@ -0,0 +1,224 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+	log "github.com/sirupsen/logrus"
+)
+
+// Failed idempotent requests are retried with jittered exponential backoff while
+// the probe has time budget left. A circuit breaker per target fast-fails
+// requests while the target is known to be down, so an offline printer does not
+// cost a connection timeout for every module on every probe.
+
+const retryBaseDelay = 100 * time.Millisecond
+
+// Circuit breaker states, as reported by klipper_exporter_target_circuit_state
+const (
+	circuitClosed   = 0
+	circuitHalfOpen = 1
+	circuitOpen     = 2
+)
+
+var errCircuitOpen = errors.New("circuit breaker open, target is unavailable")
+
+// CircuitBreakerConfig configures the per-target circuit breaker.
+type CircuitBreakerConfig struct {
+	// Failures is the number of consecutive failed requests that opens the
+	// circuit. The circuit breaker is disabled when zero.
+	Failures int
+	// Cooldown is how long the circuit stays open before a trial request is
+	// allowed through.
+	Cooldown time.Duration
+}
+
+// targetUnavailable reports whether the error means Moonraker could not be
+// reached or failed to handle the request, as opposed to rejecting it.
+func targetUnavailable(err error) bool {
+	var statusErr *moonraker.StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= 500
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+type circuitBreaker struct {
+	// gauge is the klipper_exporter_target_circuit_state series of the target
+	gauge prometheus.Gauge
+	// lastUsed is guarded by State.circuitBreakersMu
+	lastUsed time.Time
+
+	mu       sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+	// trial is set while the single half-open trial request is in flight
+	trial bool
+}
+
+// circuitBreakerFor returns the circuit breaker of the target. The breakers of
+// targets that were not probed for stateIdleTimeout are removed, together with
+// their klipper_exporter_target_circuit_state series.
+func (s *State) circuitBreakerFor(target string) *circuitBreaker {
+	s.circuitBreakersMu.Lock()
+	defer s.circuitBreakersMu.Unlock()
+
+	s.evictIdleCircuitBreakers()
+	breaker, ok := s.circuitBreakers[target]
+	if !ok {
+		breaker = &circuitBreaker{gauge: s.circuitState.WithLabelValues(RedactTarget(target))}
+		breaker.gauge.Set(circuitClosed)
+		s.circuitBreakers[target] = breaker
+	}
+	breaker.lastUsed = time.Now()
+	return breaker
+}
+
+// evictIdleCircuitBreakers removes the circuit breakers of the targets that were
+// not probed for stateIdleTimeout. Called with circuitBreakersMu held.
+func (s *State) evictIdleCircuitBreakers() {
+	for target, breaker := range s.circuitBreakers {
+		if time.Since(breaker.lastUsed) > stateIdleTimeout {
+			delete(s.circuitBreakers, target)
+			s.deleteCircuitState(RedactTarget(target))
+		}
+	}
+}
+
+// deleteCircuitState deletes the circuit state series, unless it is shared with
+// a remaining target that has the same redacted address.
+func (s *State) deleteCircuitState(label string) {
+	for t := range s.circuitBreakers {
+		if RedactTarget(t) == label {
+			return
+		}
+	}
+	s.circuitState.DeleteLabelValues(label)
+}
+
+// allow reports whether a request may be sent to the target.
+func (b *circuitBreaker) allow(cfg CircuitBreakerConfig) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < cfg.Cooldown {
+			return false
+		}
+		b.setState(circuitHalfOpen)
+		b.trial = true
+		return true
+	case circuitHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	}
+	return true
+}
+
+// record updates the breaker with the outcome of a request.
+func (b *circuitBreaker) record(target string, cfg CircuitBreakerConfig, available bool, logger log.FieldLogger) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	if available {
+		b.failures = 0
+		if b.state != circuitClosed {
+			logger.Infof("Circuit breaker closed for %s", RedactTarget(target))
+			b.setState(circuitClosed)
+		}
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= cfg.Failures {
+		if b.state != circuitOpen {
+			logger.Warnf("Circuit breaker open for %s after %d failed requests", RedactTarget(target), b.failures)
+		}
+		b.openedAt = time.Now()
+		b.setState(circuitOpen)
+	}
+}
+
+// abort releases the half-open trial without recording an outcome.
+func (b *circuitBreaker) abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+func (b *circuitBreaker) setState(state int) {
+	b.state = state
+	b.gauge.Set(float64(state))
+}
+
+// RedactTarget removes any password from the target for use in metric labels
+// and on the status page.
+func RedactTarget(target string) string {
+	if u, err := url.Parse(target); err == nil && u.User != nil {
+		return u.Redacted()
+	}
+	return target
+}
+
+// retryDelay returns the jittered exponential backoff delay for the attempt.
+func retryDelay(attempt int) time.Duration {
+	delay := retryBaseDelay << attempt
+	return delay/2 + rand.N(delay/2+1)
+}
+
+// resilientRequest sends the request through the target circuit breaker,
+// retrying idempotent requests that fail because the target is unavailable for
+// as long as the probe time budget allows.
+func (c Collector) resilientRequest(ctx context.Context, next moonraker.RequestFunc, method, urlPath string, body []byte) ([]byte, error) {
+	breakerConfig := c.config.CircuitBreaker
+	var breaker *circuitBreaker
+	if breakerConfig.Failures > 0 {
+		breaker = c.state.circuitBreakerFor(c.target)
+	}
+
+	for attempt := 0; ; attempt++ {
+		if breaker != nil && !breaker.allow(breakerConfig) {
+			return nil, fmt.Errorf("unable to request %s: %w", urlPath, errCircuitOpen)
+		}
+
+		data, err := next(ctx, method, urlPath, body)
+		unavailable := err != nil && ctx.Err() == nil && targetUnavailable(err)
+		if breaker != nil {
+			if ctx.Err() != nil {
+				// the probe ran out of time budget, the outcome says nothing about the target
+				breaker.abort()
+			} else {
+				breaker.record(c.target, breakerConfig, !unavailable, c.logger)
+			}
+		}
+		if !unavailable || method != "GET" || attempt >= c.config.Retries {
+			return data, err
+		}
+
+		delay := retryDelay(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return data, err
+		}
+		c.logger.Debugf("Retrying %s for %s in %s: %v", urlPath, c.target, delay, err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
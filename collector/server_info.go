@@ -5,47 +5,52 @@ import (
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
 )
 
-type MoonrakerServerInfoResponse struct {
-	Result MoonrakerServerInfo `json:"result"`
-}
+// Metrics of the server_info module
+var (
+	klippyConnectedDesc      = newGauge("server_info", "klipper_klippy_connected", "Whether Klippy is connected.")
+	klippyStateInfoDesc      = newGauge("server_info", "klipper_klippy_state_info", "The current state of Klippy.", "state")
+	componentInfoDesc        = newGauge("server_info", "klipper_component_info", "A registered Moonraker component.", "component")
+	componentFailedInfoDesc  = newGauge("server_info", "klipper_component_failed_info", "A Moonraker component that failed to load.", "failed_component")
+	moonrakerVersionInfoDesc = newGauge("server_info", "klipper_moonraker_version_info", "Moonraker version.", "version")
+	apiVersionInfoDesc       = newGauge("server_info", "klipper_api_version_info", "Moonraker API version.", "version")
+)
 
-type MoonrakerServerInfo struct {
-	KlippyConnected  bool     `json:"klippy_connected"`
-	KlippyState      string   `json:"klippy_state"`
-	Components       []string `json:"components"`
-	FailedComponents []string `json:"failed_components"`
-	MoonrakerVersion string   `json:"moonraker_version"`
-	APIVersion       []int    `json:"api_version"`
+func init() {
+	RegisterModule(builtinModule{
+		name:           "server_info",
+		defaultEnabled: true,
+		endpoints:      []string{"/server/info"},
+		collect:        Collector.collectServerInfo,
+	})
 }
 
-func (c Collector) collectServerInfo(ch chan<- prometheus.Metric) {
-	var result MoonrakerServerInfoResponse
-	if err := c.fetchFromMoonraker("/server/info", &result); err != nil {
-		log.Error(err)
-		return
+func (c Collector) collectServerInfo(ch chan<- prometheus.Metric) error {
+	info, err := c.Client().ServerInfo(c.ctx)
+	if err != nil {
+		return err
 	}
 
-	c.emitGauge(ch, "klipper_klippy_connected", "Whether Klippy is connected.", boolToFloat64(result.Result.KlippyConnected))
-	emitStateInfoMetric(ch, "klipper_klippy_state_info", "The current state of Klippy.", "state", result.Result.KlippyState)
+	klippyConnectedDesc.emit(ch, boolToFloat64(info.KlippyConnected))
+	klippyStateInfoDesc.emitInfo(ch, info.KlippyState)
 
-	for _, component := range result.Result.Components {
-		emitStateInfoMetric(ch, "klipper_component_info", "A registered Moonraker component.", "component", component)
+	for _, component := range info.Components {
+		componentInfoDesc.emitInfo(ch, component)
 	}
-	for _, component := range result.Result.FailedComponents {
-		emitStateInfoMetric(ch, "klipper_component_failed_info", "A Moonraker component that failed to load.", "failed_component", component)
+	for _, component := range info.FailedComponents {
+		componentFailedInfoDesc.emitInfo(ch, component)
 	}
 
-	if result.Result.MoonrakerVersion != "" {
-		emitStateInfoMetric(ch, "klipper_moonraker_version_info", "Moonraker version.", "version", result.Result.MoonrakerVersion)
+	if info.MoonrakerVersion != "" {
+		moonrakerVersionInfoDesc.emitInfo(ch, info.MoonrakerVersion)
 	}
 
-	if len(result.Result.APIVersion) > 0 {
-		versionStr := formatAPIVersion(result.Result.APIVersion)
-		emitStateInfoMetric(ch, "klipper_api_version_info", "Moonraker API version.", "version", versionStr)
+	if len(info.APIVersion) > 0 {
+		versionStr := formatAPIVersion(info.APIVersion)
+		apiVersionInfoDesc.emitInfo(ch, versionStr)
 	}
+	return nil
 }
 
 func formatAPIVersion(parts []int) string {
@@ -0,0 +1,106 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+)
+
+// stateIdleTimeout is how long the state of a target, or of the settings of a
+// probe, is kept after it was last used.
+const stateIdleTimeout = 10 * time.Minute
+
+// State holds what the collectors of an exporter share between probes: the
+// Moonraker clients and login sessions, the in-flight requests and response
+// cache, the circuit breakers, the printer object subscriptions, and the custom
+// sensors and modules detected on each printer. What is kept for a target, or
+// for the connection settings of a probe, is removed once it has not been used
+// for stateIdleTimeout, so that the state doesn't grow with every target ever
+// probed. Collectors created without WithState get a state of their own.
+//
+// State is a prometheus.Collector for the exporter metrics about the shared
+// state, e.g. klipper_exporter_cache_hits_total, and is usually registered on
+// the registry serving `/metrics`.
+type State struct {
+	clientsMu sync.Mutex
+	// clients are shared between probes of a target with the same connection
+	// settings, so that the access token of a user login is reused.
+	clients map[string]*sharedClient
+
+	httpClientsMu sync.Mutex
+	// httpClients are shared by all collectors with the same TLS settings so that
+	// connections to Moonraker are reused between probes. Request deadlines are
+	// taken from the probe context.
+	httpClients map[TLSConfig]*sharedHTTPClient
+
+	inflightRequests singleflight.Group
+	responseCacheMu  sync.Mutex
+	responseCache    map[string]cachedResponse
+
+	circuitBreakersMu sync.Mutex
+	circuitBreakers   map[string]*circuitBreaker
+
+	subscriptionsMu sync.Mutex
+	subscriptions   map[string]*subscription
+
+	customSensorsMu sync.Mutex
+	customSensors   map[string]*customSensors
+
+	detectedMu sync.Mutex
+	detected   map[string]detectedModules
+
+	cacheHits    *prometheus.CounterVec
+	cacheMisses  *prometheus.CounterVec
+	circuitState *prometheus.GaugeVec
+	emitErrors   *prometheus.CounterVec
+}
+
+// NewState returns an empty state.
+func NewState() *State {
+	return &State{
+		clients:         map[string]*sharedClient{},
+		httpClients:     map[TLSConfig]*sharedHTTPClient{},
+		responseCache:   map[string]cachedResponse{},
+		circuitBreakers: map[string]*circuitBreaker{},
+		subscriptions:   map[string]*subscription{},
+		customSensors:   map[string]*customSensors{},
+		detected:        map[string]detectedModules{},
+		cacheHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "klipper_exporter_cache_hits_total",
+			Help: "Number of Moonraker requests served from the response cache or a concurrent in-flight request.",
+		}, []string{"endpoint"}),
+		cacheMisses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "klipper_exporter_cache_misses_total",
+			Help: "Number of Moonraker requests sent to Moonraker.",
+		}, []string{"endpoint"}),
+		circuitState: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "klipper_exporter_target_circuit_state",
+			Help: "Circuit breaker state for the target, closed (0), half-open (1), or open (2).",
+		}, []string{"target"}),
+		emitErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "klipper_exporter_emit_errors_total",
+			Help: "Number of metric series dropped because they could not be created, e.g. for a label value that is not valid UTF-8.",
+		}, []string{"module", "metric"}),
+	}
+}
+
+// Describe implements Prometheus.Collector.
+func (s *State) Describe(ch chan<- *prometheus.Desc) {
+	s.cacheHits.Describe(ch)
+	s.cacheMisses.Describe(ch)
+	s.circuitState.Describe(ch)
+	s.emitErrors.Describe(ch)
+}
+
+// Collect implements Prometheus.Collector.
+func (s *State) Collect(ch chan<- prometheus.Metric) {
+	s.cacheHits.Collect(ch)
+	s.cacheMisses.Collect(ch)
+	s.circuitBreakersMu.Lock()
+	s.evictIdleCircuitBreakers()
+	s.circuitBreakersMu.Unlock()
+	s.circuitState.Collect(ch)
+	s.emitErrors.Collect(ch)
+}
@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ModuleStatus is the outcome of collecting a single module during a probe.
+type ModuleStatus struct {
+	Module   string  `json:"module"`
+	Success  bool    `json:"success"`
+	Duration float64 `json:"duration_seconds"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// ProbeStatus is the outcome of the last probe of a target, as shown on the
+// exporter status page.
+type ProbeStatus struct {
+	Target   string         `json:"target"`
+	Address  string         `json:"address"`
+	Time     time.Time      `json:"last_probe"`
+	Duration float64        `json:"duration_seconds"`
+	Up       bool           `json:"up"`
+	Modules  []ModuleStatus `json:"modules"`
+}
+
+// probeStatus holds the status of the last Collect call, shared between the
+// copies of the Collector value.
+type probeStatus struct {
+	mu     sync.Mutex
+	status ProbeStatus
+}
+
+// recordStatus stores the module results of a Collect call that started at start.
+func (c Collector) recordStatus(start time.Time, results []moduleResult) {
+	if c.status == nil {
+		return
+	}
+	address := RedactTarget(c.target)
+	status := ProbeStatus{
+		Target:   address,
+		Address:  address,
+		Time:     start,
+		Duration: time.Since(start).Seconds(),
+		Modules:  make([]ModuleStatus, 0, len(results)),
+	}
+	for _, result := range results {
+		module := ModuleStatus{Module: result.module, Success: result.err == nil, Duration: result.duration.Seconds()}
+		if result.err != nil {
+			module.Error = strings.ReplaceAll(result.err.Error(), c.target, address)
+		}
+		status.Up = status.Up || module.Success
+		status.Modules = append(status.Modules, module)
+	}
+	sort.Slice(status.Modules, func(i, j int) bool {
+		return status.Modules[i].Module < status.Modules[j].Module
+	})
+
+	c.status.mu.Lock()
+	defer c.status.mu.Unlock()
+	c.status.status = status
+}
+
+// Status returns the outcome of the last Collect call. The returned status has
+// no modules if the collector has not been collected yet.
+func (c Collector) Status() ProbeStatus {
+	if c.status == nil {
+		return ProbeStatus{}
+	}
+	c.status.mu.Lock()
+	defer c.status.mu.Unlock()
+	return c.status.status
+}
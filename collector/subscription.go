@@ -0,0 +1,344 @@
+package collector
+
+// Persistent printer object subscription
+// https://moonraker.readthedocs.io/en/latest/external_api/printer/#subscribe-to-printer-object-status
+// https://moonraker.readthedocs.io/en/latest/external_api/jsonrpc_notifications/#klipper-status-update
+//
+// When enabled, the exporter keeps a JSON-RPC connection open per target, using
+// the `/websocket` endpoint or the unix socket for `unix://` targets. The objects
+// queried by the `printer_objects` module are subscribed with
+// `printer.objects.subscribe`, and `notify_status_update` diffs are merged into an
+// in-memory status that is served on each probe.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// subscriptionIdleTimeout closes subscriptions for targets that are no longer probed.
+	subscriptionIdleTimeout = 10 * time.Minute
+	// subscriptionMaxBackoff is the maximum delay between reconnect attempts.
+	subscriptionMaxBackoff = 30 * time.Second
+	// subscriptionHandshakeTimeout bounds connecting and subscribing to Moonraker.
+	subscriptionHandshakeTimeout = 10 * time.Second
+)
+
+type MoonrakerSubscribeResult struct {
+	Status map[string]map[string]interface{} `json:"status"`
+}
+
+// subscription holds the printer object status for a target, kept up to date
+// from a persistent JSON-RPC connection.
+type subscription struct {
+	target string
+	// key is the connection key of the probes sharing the subscription
+	key    string
+	state  *State
+	logger log.FieldLogger
+	// config and httpClient are the settings of the probe that started the
+	// subscription, the same for every probe with the key
+	config     ClientConfig
+	httpClient *http.Client
+
+	mu            sync.Mutex
+	status        map[string]map[string]interface{}
+	connected     bool
+	everConnected bool
+	ready         bool
+	reconnects    int
+	lastProbe     time.Time
+
+	// attempted is closed after the first connection attempt completes, either
+	// with the initial status or with an error.
+	attempted     chan struct{}
+	attemptedOnce sync.Once
+}
+
+// subscriptionFor returns the subscription for the collector target and
+// connection settings, starting it if needed. Probes with other credentials or
+// TLS settings get a subscription of their own. The second return value is true
+// when the subscription was started.
+func (c Collector) subscriptionFor() (*subscription, bool) {
+	key := connectionKey(c.target, c.config, c.httpClient)
+
+	c.state.subscriptionsMu.Lock()
+	defer c.state.subscriptionsMu.Unlock()
+
+	sub, ok := c.state.subscriptions[key]
+	if !ok {
+		sub = &subscription{
+			target:     c.target,
+			key:        key,
+			state:      c.state,
+			logger:     c.logger,
+			config:     c.config,
+			httpClient: c.httpClient,
+			attempted:  make(chan struct{}),
+		}
+		c.state.subscriptions[key] = sub
+	}
+	sub.mu.Lock()
+	sub.lastProbe = time.Now()
+	sub.mu.Unlock()
+
+	if !ok {
+		c.logger.Infof("Starting printer object subscription for %s", c.target)
+		go sub.run()
+	}
+	return sub, !ok
+}
+
+// subscribedPrinterObjects returns the subscribed printer object status. A new
+// subscription is given the probe time budget to receive the initial status.
+func (c Collector) subscribedPrinterObjects() (*PrinterObjectResponse, bool) {
+	sub, started := c.subscriptionFor()
+	if started {
+		select {
+		case <-sub.attempted:
+		case <-c.ctx.Done():
+		}
+	}
+
+	sub.mu.Lock()
+	if !sub.ready {
+		sub.mu.Unlock()
+		return nil, false
+	}
+	status, err := json.Marshal(sub.status)
+	sub.mu.Unlock()
+	if err != nil {
+		c.logger.Warnf("Unable to marshal subscribed status for %s: %v", c.target, err)
+		return nil, false
+	}
+
+	var response PrinterObjectResponse
+	if err := json.Unmarshal(status, &response.Result.Status); err != nil {
+		c.logger.Warnf("Unable to unmarshal subscribed status for %s: %v", c.target, err)
+		return nil, false
+	}
+	return &response, true
+}
+
+// Subscription metrics of the printer_objects module
+var (
+	subscriptionConnectedDesc  = newGauge("printer_objects", "klipper_exporter_subscription_connected", "Whether the printer object subscription is connected to Moonraker (1) or not (0).")
+	subscriptionReconnectsDesc = newCounter("printer_objects", "klipper_exporter_subscription_reconnects_total", "Number of times the printer object subscription reconnected to Moonraker.")
+)
+
+// collectSubscriptionStatus reports the state of the websocket subscription.
+func (c Collector) collectSubscriptionStatus(ch chan<- prometheus.Metric) {
+	c.state.subscriptionsMu.Lock()
+	sub, ok := c.state.subscriptions[connectionKey(c.target, c.config, c.httpClient)]
+	c.state.subscriptionsMu.Unlock()
+	if !ok {
+		return
+	}
+
+	sub.mu.Lock()
+	connected, reconnects := sub.connected, sub.reconnects
+	sub.mu.Unlock()
+	subscriptionConnectedDesc.emit(ch, boolToFloat64(connected))
+	subscriptionReconnectsDesc.emit(ch, float64(reconnects))
+}
+
+// run maintains the subscription connection, reconnecting with backoff until
+// the target has not been probed for subscriptionIdleTimeout.
+func (s *subscription) run() {
+	backoff := time.Second
+	for {
+		if s.idle() {
+			s.logger.Infof("Stopping idle printer object subscription for %s", s.target)
+			s.state.subscriptionsMu.Lock()
+			delete(s.state.subscriptions, s.key)
+			s.state.subscriptionsMu.Unlock()
+			return
+		}
+
+		received, err := s.serve()
+		s.mu.Lock()
+		s.connected, s.ready = false, false
+		s.mu.Unlock()
+		s.attemptedOnce.Do(func() { close(s.attempted) })
+		if received {
+			backoff = time.Second
+		}
+		s.logger.Warnf("Printer object subscription for %s disconnected, reconnecting in %s: %v", s.target, backoff, err)
+
+		time.Sleep(backoff)
+		backoff = min(backoff*2, subscriptionMaxBackoff)
+	}
+}
+
+func (s *subscription) idle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Since(s.lastProbe) > subscriptionIdleTimeout
+}
+
+// serve connects, subscribes and processes status updates until the connection
+// fails. Returns true if the initial status was received.
+func (s *subscription) serve() (bool, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config, httpClient := s.config, s.httpClient
+
+	handshakeCtx, handshakeCancel := context.WithTimeout(ctx, subscriptionHandshakeTimeout)
+	defer handshakeCancel()
+	c := Collector{
+		ctx:        handshakeCtx,
+		target:     s.target,
+		config:     config,
+		httpClient: httpClient,
+		logger:     s.logger,
+		state:      s.state,
+		client:     s.state.clientFor(s.target, config, httpClient, s.logger),
+	}
+
+	conn, err := c.client.Connect(handshakeCtx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	// close the connection when it is no longer probed so the read loop exits
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if s.idle() {
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+	stopHandshake := context.AfterFunc(handshakeCtx, func() {
+		if handshakeCtx.Err() == context.DeadlineExceeded {
+			conn.Close()
+		}
+	})
+
+	if err := conn.Identify(handshakeCtx, exporterIdentity()); err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	if s.everConnected {
+		s.reconnects++
+	}
+	s.connected, s.everConnected = true, true
+	s.mu.Unlock()
+
+	subscribeID, err := c.subscribe(conn)
+	if err != nil {
+		return false, err
+	}
+
+	received := false
+	for {
+		message, err := conn.ReadMessage()
+		if err != nil {
+			return received, err
+		}
+
+		switch {
+		case message.ID != nil && *message.ID == subscribeID:
+			if message.Error != nil {
+				return received, fmt.Errorf("unable to subscribe to printer objects: %d %s", message.Error.Code, message.Error.Message)
+			}
+			var result MoonrakerSubscribeResult
+			if err := json.Unmarshal(message.Result, &result); err != nil {
+				return received, fmt.Errorf("unable to parse subscription status: %w", err)
+			}
+			stopHandshake()
+			s.mu.Lock()
+			s.status = result.Status
+			s.ready = true
+			s.mu.Unlock()
+			if !received {
+				s.logger.Infof("Subscribed to printer objects for %s", s.target)
+			}
+			received = true
+			s.attemptedOnce.Do(func() { close(s.attempted) })
+		case message.Method == "notify_status_update":
+			var params []json.RawMessage
+			var diff map[string]map[string]interface{}
+			if err := json.Unmarshal(message.Params, &params); err != nil || len(params) == 0 {
+				continue
+			}
+			if err := json.Unmarshal(params[0], &diff); err != nil {
+				continue
+			}
+			s.merge(diff)
+		case message.Method == "notify_klippy_ready":
+			// subscriptions are dropped when Klipper restarts
+			subscribeCtx, subscribeCancel := context.WithTimeout(ctx, subscriptionHandshakeTimeout)
+			c.ctx = subscribeCtx
+			subscribeID, err = c.subscribe(conn)
+			subscribeCancel()
+			if err != nil {
+				return received, err
+			}
+		case message.Method == "notify_klippy_disconnected" || message.Method == "notify_klippy_shutdown":
+			s.mu.Lock()
+			s.ready = false
+			s.mu.Unlock()
+		}
+	}
+}
+
+// merge applies a `notify_status_update` diff to the subscribed status.
+func (s *subscription) merge(diff map[string]map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.status == nil {
+		return
+	}
+	for object, attributes := range diff {
+		current, ok := s.status[object]
+		if !ok {
+			current = make(map[string]interface{})
+			s.status[object] = current
+		}
+		for name, value := range attributes {
+			current[name] = value
+		}
+	}
+}
+
+// exporterIdentity identifies the exporter to Moonraker.
+func exporterIdentity() moonraker.Identity {
+	version := "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		version = info.Main.Version
+	}
+	return moonraker.Identity{
+		ClientName: "prometheus-klipper-exporter",
+		Version:    version,
+		Type:       "other",
+		URL:        "https://github.com/scross01/prometheus-klipper-exporter",
+	}
+}
+
+// subscribe requests a subscription to the printer objects collected by the
+// `printer_objects` module and returns the request id.
+func (c Collector) subscribe(conn *moonraker.Conn) (int64, error) {
+	objects, err := c.printerObjects()
+	if err != nil {
+		return 0, err
+	}
+	return conn.SubscribePrinterObjects(objects)
+}
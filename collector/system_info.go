@@ -4,63 +4,50 @@ package collector
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
-	log "github.com/sirupsen/logrus"
 )
 
-type MoonrakerSystemInfoQueryResponse struct {
-	Result struct {
-		SystemInfo struct {
-			CpuInfo struct {
-				CpuCount    int    `json:"cpu_count"`
-				TotalMemory int    `json:"total_memory"`
-				MemoryUnits string `json:"memory_units"`
-			} `json:"cpu_info"`
-			AvailableServices []string `json:"available_services"`
-			ServiceState      map[string]struct {
-				ActiveState string `json:"active_state"`
-				SubState    string `json:"sub_state"`
-			} `json:"service_state"`
-		} `json:"system_info"`
-	} `json:"result"`
+// Metrics of the system_info module
+var (
+	systemCpuCountDesc      = newGauge("system_info", "klipper_system_cpu_count", "Klipper system CPU count.")
+	serviceAvailableDesc    = newGauge("system_info", "klipper_service_available", "Klipper host service availability. Always 1 when present.", "service")
+	serviceStateInfoDesc    = newGauge("system_info", "klipper_service_state_info", "Klipper host service state.", "service", "state")
+	serviceSubStateInfoDesc = newGauge("system_info", "klipper_service_sub_state_info", "Klipper host service sub-state.", "service", "sub_state")
+)
+
+func init() {
+	RegisterModule(builtinModule{
+		name:           "system_info",
+		defaultEnabled: true,
+		endpoints:      []string{"/machine/system_info"},
+		collect:        Collector.collectSystemInfo,
+	})
 }
 
-func (c Collector) collectSystemInfo(ch chan<- prometheus.Metric) {
-	var result MoonrakerSystemInfoQueryResponse
-	if err := c.fetchFromMoonraker("/machine/system_info", &result); err != nil {
-		log.Error(err)
-		return
+func (c Collector) collectSystemInfo(ch chan<- prometheus.Metric) error {
+	systemInfo, err := c.Client().SystemInfo(c.ctx)
+	if err != nil {
+		return err
 	}
 
 	// CPU count
-	c.emitGauge(ch, "klipper_system_cpu_count",
-		"Klipper system CPU count.",
-		float64(result.Result.SystemInfo.CpuInfo.CpuCount))
+	systemCpuCountDesc.emit(ch, float64(systemInfo.CpuInfo.CpuCount))
 
 	// Iterate available_services and look up each in service_state to emit consistent metrics
-	for _, service := range result.Result.SystemInfo.AvailableServices {
-		labelName := GetValidLabelName(service)
+	for _, service := range systemInfo.AvailableServices {
+		labelName := c.labelName(service)
 
 		// Emit availability metric
-		emitStateInfoMetric(ch, "klipper_service_available",
-			"Klipper host service availability. Always 1 when present.",
-			"service", labelName)
+		serviceAvailableDesc.emitInfo(ch, labelName)
 
 		// Look up the service in service_state
-		if serviceStatus, exists := result.Result.SystemInfo.ServiceState[service]; exists {
-			emitStateInfoMetric2(ch, "klipper_service_state_info",
-				"Klipper host service state.",
-				"service", labelName, "state", serviceStatus.ActiveState)
-			emitStateInfoMetric2(ch, "klipper_service_sub_state_info",
-				"Klipper host service sub-state.",
-				"service", labelName, "sub_state", serviceStatus.SubState)
+		if serviceStatus, exists := systemInfo.ServiceState[service]; exists {
+			serviceStateInfoDesc.emitInfo(ch, labelName, serviceStatus.ActiveState)
+			serviceSubStateInfoDesc.emitInfo(ch, labelName, serviceStatus.SubState)
 		} else {
 			// Service is available but has no state — emit unknown sentinels
-			emitStateInfoMetric2(ch, "klipper_service_state_info",
-				"Klipper host service state.",
-				"service", labelName, "state", "unknown")
-			emitStateInfoMetric2(ch, "klipper_service_sub_state_info",
-				"Klipper host service sub-state.",
-				"service", labelName, "sub_state", "unknown")
+			serviceStateInfoDesc.emitInfo(ch, labelName, "unknown")
+			serviceSubStateInfoDesc.emitInfo(ch, labelName, "unknown")
 		}
 	}
+	return nil
 }
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// TargetAllowlist restricts the targets `/probe` will request to IP addresses in
+// a list of CIDRs, or hostnames matching a list of glob patterns. Hostnames are
+// not resolved, so CIDRs only match targets given as IP addresses.
+type TargetAllowlist struct {
+	networks []*net.IPNet
+	patterns []string
+}
+
+// ParseTargetAllowlist parses allowlist entries. Each entry is a CIDR such as
+// `192.168.1.0/24`, a hostname or IP address, or a glob pattern such as
+// `*.farm.lan`, `printer-?:7125` or `unix:///home/pi/printer_data/comms/*`.
+func ParseTargetAllowlist(entries []string) (*TargetAllowlist, error) {
+	a := &TargetAllowlist{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			a.networks = append(a.networks, network)
+			continue
+		}
+		if _, err := path.Match(entry, ""); err != nil {
+			return nil, fmt.Errorf("invalid allowed target pattern %q: %w", entry, err)
+		}
+		// socket paths are case-sensitive, unlike hostnames
+		if !strings.HasPrefix(entry, "unix://") {
+			entry = strings.ToLower(entry)
+		}
+		a.patterns = append(a.patterns, entry)
+	}
+	return a, nil
+}
+
+// Empty reports whether the allowlist has no entries.
+func (a *TargetAllowlist) Empty() bool {
+	return a == nil || (len(a.networks) == 0 && len(a.patterns) == 0)
+}
+
+// Allowed reports whether the target matches an entry in the allowlist. The
+// patterns are matched against the target hostname and the host and port, or
+// for `unix://` targets against the socket path. Targets with user info, a
+// query, or a fragment are never allowed, so that the part of the target that
+// is matched is the part that is requested.
+func (a *TargetAllowlist) Allowed(target string) bool {
+	if a.Empty() || strings.ContainsAny(target, "?#\\") {
+		return false
+	}
+
+	var candidates []string
+	var ip net.IP
+	if socketPath, ok := strings.CutPrefix(target, "unix://"); ok {
+		if socketPath == "" || path.Clean(socketPath) != socketPath {
+			return false
+		}
+		candidates = append(candidates, "unix://"+socketPath)
+	} else {
+		address := target
+		if !strings.Contains(address, "://") {
+			address = "http://" + address
+		}
+		u, err := url.Parse(address)
+		if err != nil || u.Hostname() == "" || u.User != nil || u.Opaque != "" {
+			return false
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return false
+		}
+		candidates = append(candidates, strings.ToLower(u.Hostname()), strings.ToLower(u.Host))
+		ip = net.ParseIP(u.Hostname())
+	}
+
+	if ip != nil {
+		for _, network := range a.networks {
+			if network.Contains(ip) {
+				return true
+			}
+		}
+	}
+	for _, pattern := range a.patterns {
+		for _, candidate := range candidates {
+			if matched, _ := path.Match(pattern, candidate); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
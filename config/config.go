@@ -0,0 +1,218 @@
+// Package config loads the exporter configuration file, which defines named
+// Moonraker targets with their connection settings, credentials, modules and
+// static labels, and named module profiles.
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v2"
+)
+
+var labelNameRegex = regexp.MustCompile("^[a-zA-Z_][a-zA-Z0-9_]*$")
+
+// Config is the exporter configuration file.
+type Config struct {
+	// Targets are keyed by the name used in the `target` probe parameter.
+	Targets map[string]Target `yaml:"targets"`
+	// Profiles are lists of modules keyed by the name used in the `profile`
+	// probe parameter. The `default` profile is used when a probe doesn't set
+	// the profile or modules.
+	Profiles map[string][]string `yaml:"profiles,omitempty"`
+	Probe    ProbeConfig         `yaml:"probe,omitempty"`
+
+	allowlist *TargetAllowlist
+}
+
+// ProbeConfig restricts the targets that can be probed. Named targets are always
+// allowed. When no restrictions are set any target can be probed.
+type ProbeConfig struct {
+	// AllowedTargets are CIDRs, hostnames, or glob patterns, see ParseTargetAllowlist.
+	AllowedTargets []string `yaml:"allowed_targets,omitempty"`
+	// ConfiguredTargetsOnly rejects all targets that are not named targets.
+	ConfiguredTargetsOnly bool `yaml:"configured_targets_only,omitempty"`
+}
+
+// Target is a named Moonraker instance.
+type Target struct {
+	// Address is the Moonraker address, e.g. `klipper-host:7125`,
+	// `https://farm.lan/printer3/` or `unix:///home/pi/printer_data/comms/moonraker.sock`.
+	Address string `yaml:"address"`
+	APIKey  string `yaml:"api_key,omitempty"`
+	// APIKeyFile is read for the API key, and re-read when it changes.
+	APIKeyFile string `yaml:"api_key_file,omitempty"`
+	// Username and Password log in as a Moonraker user instead of using the API key.
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	// PasswordFile is read for the password, and re-read when it changes.
+	PasswordFile string `yaml:"password_file,omitempty"`
+	// Profile and Modules replace the profile and modules from the probe
+	// parameters when set.
+	Profile string   `yaml:"profile,omitempty"`
+	Modules []string `yaml:"modules,omitempty"`
+	// Labels are added to every metric of the target, e.g. printer name and room.
+	Labels map[string]string `yaml:"labels,omitempty"`
+	// Include and Exclude are regular expressions matching the metrics to
+	// collect and not to collect, and replace the `include` and `exclude` probe
+	// parameters when set. FilterLabels matches them against the label values
+	// as well as the metric names.
+	Include      []string `yaml:"include,omitempty"`
+	Exclude      []string `yaml:"exclude,omitempty"`
+	FilterLabels *bool    `yaml:"filter_labels,omitempty"`
+
+	BasePath  string            `yaml:"base_path,omitempty"`
+	Headers   map[string]string `yaml:"headers,omitempty"`
+	TLS       TLSConfig         `yaml:"tls,omitempty"`
+	Subscribe *bool             `yaml:"subscribe,omitempty"`
+	CacheTTL  *time.Duration    `yaml:"cache_ttl,omitempty"`
+}
+
+// TLSConfig configures TLS for `https://` target addresses. Fields that are set
+// override the command line options.
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file,omitempty"`
+	CertFile           string `yaml:"cert_file,omitempty"`
+	KeyFile            string `yaml:"key_file,omitempty"`
+	ServerName         string `yaml:"server_name,omitempty"`
+	InsecureSkipVerify *bool  `yaml:"insecure_skip_verify,omitempty"`
+}
+
+// Load reads and validates the configuration file.
+func Load(filename string) (*Config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file: %w", err)
+	}
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("unable to parse config file %s: %w", filename, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", filename, err)
+	}
+	return cfg, nil
+}
+
+// Validate checks the target definitions and probe restrictions.
+func (c *Config) Validate() error {
+	allowlist, err := ParseTargetAllowlist(c.Probe.AllowedTargets)
+	if err != nil {
+		return err
+	}
+	c.allowlist = allowlist
+
+	for name, modules := range c.Profiles {
+		if name == "" {
+			return fmt.Errorf("profile name must not be empty")
+		}
+		if len(modules) == 0 {
+			return fmt.Errorf("profile %s: modules must be set", name)
+		}
+	}
+	for name, target := range c.Targets {
+		if name == "" {
+			return fmt.Errorf("target name must not be empty")
+		}
+		if target.Address == "" {
+			return fmt.Errorf("target %s: address must be set", name)
+		}
+		if target.APIKey != "" && target.APIKeyFile != "" {
+			return fmt.Errorf("target %s: only one of api_key and api_key_file can be set", name)
+		}
+		if (target.APIKey != "" || target.APIKeyFile != "") && target.Username != "" {
+			return fmt.Errorf("target %s: only one of api_key and username can be set", name)
+		}
+		if target.Password != "" && target.PasswordFile != "" {
+			return fmt.Errorf("target %s: only one of password and password_file can be set", name)
+		}
+		if (target.Password != "" || target.PasswordFile != "") && target.Username == "" {
+			return fmt.Errorf("target %s: password requires username", name)
+		}
+		if _, ok := c.Profiles[target.Profile]; target.Profile != "" && !ok {
+			return fmt.Errorf("target %s: unknown profile %s", name, target.Profile)
+		}
+		for _, pattern := range append(slices.Clone(target.Include), target.Exclude...) {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("target %s: invalid metric filter pattern %q: %w", name, pattern, err)
+			}
+		}
+		for label := range target.Labels {
+			if !labelNameRegex.MatchString(label) || strings.HasPrefix(label, "__") {
+				return fmt.Errorf("target %s: invalid label name %q", name, label)
+			}
+		}
+	}
+	return nil
+}
+
+// Credentials returns the API key or user login password of the target, reading
+// the api_key_file or password_file from the secrets if set.
+func (t Target) Credentials(secrets *Secrets) (apiKey string, password string, err error) {
+	apiKey, password = t.APIKey, t.Password
+	if t.APIKeyFile != "" {
+		if apiKey, err = secrets.Read(t.APIKeyFile); err != nil {
+			return "", "", err
+		}
+	}
+	if t.PasswordFile != "" {
+		if password, err = secrets.Read(t.PasswordFile); err != nil {
+			return "", "", err
+		}
+	}
+	return apiKey, password, nil
+}
+
+// AllowedTargets returns the parsed probe.allowed_targets allowlist.
+func (c *Config) AllowedTargets() *TargetAllowlist {
+	if c == nil {
+		return nil
+	}
+	return c.allowlist
+}
+
+// Target returns the named target.
+func (c *Config) Target(name string) (Target, bool) {
+	if c == nil {
+		return Target{}, false
+	}
+	target, ok := c.Targets[name]
+	return target, ok
+}
+
+// DefaultProfile is the profile used when a probe doesn't set the profile or
+// modules.
+const DefaultProfile = "default"
+
+// Profile returns the modules of the named profile.
+func (c *Config) Profile(name string) ([]string, bool) {
+	if c == nil {
+		return nil, false
+	}
+	modules, ok := c.Profiles[name]
+	return modules, ok
+}
+
+// Modules returns the modules of the profile, or of the default profile when
+// neither the profile nor modules to add are set, followed by the modules added,
+// or excluded with `-module`. Returns the modules unchanged when there is no
+// profile.
+func (c *Config) Modules(profile string, modules []string) ([]string, error) {
+	if profile == "" && !slices.ContainsFunc(modules, func(m string) bool { return !strings.HasPrefix(m, "-") }) {
+		if _, ok := c.Profile(DefaultProfile); ok {
+			profile = DefaultProfile
+		}
+	}
+	if profile == "" {
+		return modules, nil
+	}
+	base, ok := c.Profile(profile)
+	if !ok {
+		return nil, fmt.Errorf("unknown profile %s", profile)
+	}
+	return append(slices.Clone(base), modules...), nil
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SafeConfig holds the current configuration, which is replaced atomically on
+// reload. Probes keep using the configuration they started with.
+type SafeConfig struct {
+	filename string
+	current  atomic.Pointer[Config]
+	// reloadMu serializes reloads from SIGHUP and the reload endpoint
+	reloadMu sync.Mutex
+	// secrets are kept across reloads, since the files are re-read when they change
+	secrets Secrets
+
+	reloadSuccess     prometheus.Gauge
+	reloadSuccessTime prometheus.Gauge
+}
+
+// NewSafeConfig returns a SafeConfig for the file, registering the reload
+// metrics with the registerer. The configuration is empty until loaded.
+func NewSafeConfig(filename string, reg prometheus.Registerer) *SafeConfig {
+	s := &SafeConfig{
+		filename: filename,
+		reloadSuccess: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "klipper_exporter_config_last_reload_successful",
+			Help: "Whether the last configuration reload attempt was successful (1) or not (0).",
+		}),
+		reloadSuccessTime: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "klipper_exporter_config_last_reload_success_timestamp_seconds",
+			Help: "Timestamp of the last successful configuration reload.",
+		}),
+	}
+	s.current.Store(&Config{})
+	reg.MustRegister(s.reloadSuccess, s.reloadSuccessTime)
+	return s
+}
+
+// Get returns the current configuration.
+func (s *SafeConfig) Get() *Config {
+	return s.current.Load()
+}
+
+// Filename returns the configuration file path, empty if no file is configured.
+func (s *SafeConfig) Filename() string {
+	return s.filename
+}
+
+// Secrets returns the secret files read for the targets and command line
+// credentials.
+func (s *SafeConfig) Secrets() *Secrets {
+	return &s.secrets
+}
+
+// Reload reads the configuration file and replaces the current configuration.
+// An invalid file is rejected and the current configuration is kept.
+func (s *SafeConfig) Reload() error {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	cfg := &Config{}
+	if s.filename != "" {
+		var err error
+		if cfg, err = Load(s.filename); err != nil {
+			s.reloadSuccess.Set(0)
+			return err
+		}
+	}
+	s.current.Store(cfg)
+	s.reloadSuccess.Set(1)
+	s.reloadSuccessTime.SetToCurrentTime()
+	return nil
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Secrets such as API keys and passwords can be read from files, e.g. Docker and
+// Kubernetes secrets or systemd credentials. The files are checked on every use
+// and re-read when they change, so rotated secrets are picked up without a
+// restart or reload.
+
+type cachedSecret struct {
+	modTime time.Time
+	size    int64
+	value   string
+}
+
+// Secrets caches the contents of the secret files. The zero value is ready to
+// use.
+type Secrets struct {
+	mu    sync.Mutex
+	cache map[string]cachedSecret
+}
+
+// Read returns the contents of the secret file with surrounding whitespace
+// removed, re-reading the file when its modification time or size changes.
+func (s *Secrets) Read(filename string) (string, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return "", fmt.Errorf("unable to read secret file: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if cached, ok := s.cache[filename]; ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.value, nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("unable to read secret file: %w", err)
+	}
+	value := strings.TrimSpace(string(data))
+	if s.cache == nil {
+		s.cache = map[string]cachedSecret{}
+	}
+	s.cache[filename] = cachedSecret{modTime: info.ModTime(), size: info.Size(), value: value}
+	return value, nil
+}
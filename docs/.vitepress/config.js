@@ -34,6 +34,7 @@ export default withMermaid(defineConfig({
             { text: 'CFS', link: '/metrics/cfs' },
             { text: 'Device Power', link: '/metrics/device-power' },
             { text: 'Directory Info', link: '/metrics/directory-info' },
+            { text: 'Exporter', link: '/metrics/exporter' },
             { text: 'History', link: '/metrics/history' },
             { text: 'Job Queue', link: '/metrics/job-queue' },
             { text: 'MMU', link: '/metrics/mmu' },
@@ -1,66 +1,473 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/exporter-toolkit/web"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/scross01/prometheus-klipper-exporter/collector"
+	"github.com/scross01/prometheus-klipper-exporter/config"
 )
 
 // Command line configuration options
 var (
-	loggingLevel  = flag.String("logging.level", "info", "Logging output level. Set to one of trace, debug, info, warning, error, fatal, or panic")
-	klipperApiKey = flag.String("moonraker.apikey", "", "API Key to authenticate with the Klipper APIs.")
-	listenAddress = flag.String("web.listen-address", ":9101", "Address on which to expose metrics and web interface.")
+	configFile          = flag.String("config.file", "", "Path to the configuration file defining named targets.")
+	loggingLevel        = flag.String("logging.level", "info", "Logging output level. Set to one of trace, debug, info, warning, error, fatal, or panic")
+	klipperApiKey       = flag.String("moonraker.apikey", "", "API Key to authenticate with the Klipper APIs.")
+	klipperApiKeyFile   = flag.String("moonraker.apikey-file", "", "File containing the API Key to authenticate with the Klipper APIs. Re-read when changed.")
+	klipperUser         = flag.String("moonraker.username", "", "Moonraker user to login as instead of using an API key. The password is read from -moonraker.password-file or the MOONRAKER_PASSWORD environment variable.")
+	klipperPasswordFile = flag.String("moonraker.password-file", "", "File containing the password for the -moonraker.username user login. Re-read when changed.")
+	listenAddress       = flag.String("web.listen-address", ":9101", "Address on which to expose metrics and web interface.")
+	allowedTargets      = flag.String("probe.allowed-targets", "", "Comma separated list of CIDRs, hostnames, or glob patterns of targets that can be probed. All targets except unix:// sockets are allowed when not set.")
+	configuredOnly      = flag.Bool("probe.configured-targets-only", false, "Only allow probes of named targets from the config file.")
+	webConfigFile       = flag.String("web.config.file", "", "Path to the web configuration file to enable TLS and basic authentication on the exporter's listener.")
+	timeoutOffset       = flag.Float64("web.timeout-offset", 0.5, "Offset in seconds to subtract from the Prometheus scrape timeout.")
+	cacheTTL            = flag.Duration("moonraker.cache-ttl", 0, "Duration to cache Moonraker responses for, shared between probes of the same target, e.g. 5s. Disabled by default.")
+	maxCacheTTL         = flag.Duration("moonraker.max-cache-ttl", time.Minute, "Maximum duration the cache_ttl probe parameter can set.")
+	retries             = flag.Int("moonraker.retries", 2, "Number of times a failed Moonraker GET request is retried within the scrape timeout.")
+	cbFailures          = flag.Int("moonraker.circuit-breaker.failures", 5, "Number of consecutive failed Moonraker requests before requests to the target fast-fail. Set to 0 to disable.")
+	cbCooldown          = flag.Duration("moonraker.circuit-breaker.cooldown", 30*time.Second, "Duration requests to an unavailable target fast-fail before a trial request is allowed.")
+	subscribe           = flag.Bool("moonraker.subscribe", false, "Serve the printer_objects module from a persistent websocket subscription instead of querying on every probe.")
+
+	tlsCAFile             = flag.String("moonraker.tls.ca-file", "", "CA certificate bundle used to verify HTTPS Moonraker targets.")
+	tlsCertFile           = flag.String("moonraker.tls.cert-file", "", "Client certificate file for mTLS with HTTPS Moonraker targets.")
+	tlsKeyFile            = flag.String("moonraker.tls.key-file", "", "Client key file for mTLS with HTTPS Moonraker targets.")
+	tlsServerName         = flag.String("moonraker.tls.server-name", "", "Server name used to verify the certificate of HTTPS Moonraker targets.")
+	tlsInsecureSkipVerify = flag.Bool("moonraker.tls.insecure-skip-verify", false, "Disable certificate verification for HTTPS Moonraker targets.")
 )
 
-func handler(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
+// exporterConfig is loaded from -config.file and reloaded on SIGHUP or a POST to
+// /-/reload. Empty when no config file is set.
+var exporterConfig *config.SafeConfig
 
-	target := query.Get("target")
-	if len(query["target"]) != 1 || target == "" {
-		http.Error(w, "'target' parameter must be specified once", 400)
-		return
+// flagAllowlist is parsed from -probe.allowed-targets
+var flagAllowlist *config.TargetAllowlist
+
+// exporterState is shared by the collectors of every probe, so that responses,
+// login sessions, circuit breakers, and subscriptions are reused between probes.
+var exporterState = collector.NewState()
+
+var probeRejected = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "klipper_exporter_probe_rejected_total",
+	Help: "Number of probes rejected because the target is not allowed.",
+})
+
+// targetAllowed reports whether a target that is not a named target can be
+// probed, using the allowed targets from the command line and config file.
+// `unix://` targets give full access to Moonraker, so they are only allowed when
+// they match an entry of the allowlist.
+func targetAllowed(cfg *config.Config, target string) bool {
+	if *configuredOnly || cfg.Probe.ConfiguredTargetsOnly {
+		return false
 	}
+	if flagAllowlist.Empty() && cfg.AllowedTargets().Empty() && !strings.HasPrefix(target, "unix://") {
+		return true
+	}
+	return flagAllowlist.Allowed(target) || cfg.AllowedTargets().Allowed(target)
+}
 
-	// Set default modules
-	modules := []string{"server_info", "process_stats", "job_queue", "system_info", "query_endstops", "device_power"}
-	// get `modules` configuration passed from the prometheus.yml
-	if len(query["modules"]) > 0 {
-		modules = query["modules"]
+// defaultScrapeTimeout is used when the probe request does not include the
+// X-Prometheus-Scrape-Timeout-Seconds header, matching the Prometheus default.
+const defaultScrapeTimeout = 10.0
+
+// getTimeout returns the time budget for a probe, taken from the scrape timeout
+// Prometheus sends with each request minus the configured safety offset.
+func getTimeout(header http.Header, offset float64) (time.Duration, error) {
+	timeoutSeconds := defaultScrapeTimeout
+	if v := header.Get("X-Prometheus-Scrape-Timeout-Seconds"); v != "" {
+		var err error
+		timeoutSeconds, err = strconv.ParseFloat(v, 64)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse timeout from Prometheus header: %w", err)
+		}
 	}
-	log.Infof("Starting metrics collection of %s for %s", modules, target)
+	if offset < timeoutSeconds {
+		timeoutSeconds -= offset
+	}
+	return time.Duration(timeoutSeconds * float64(time.Second)), nil
+}
 
-	// set api key. prometheus.yml > command line arg > environment variable
-	apiKey := ""
-	auth := r.Header.Get("Authorization")
+// applyTargetConfig overrides the probe settings with the settings of a named
+// target from the config file.
+func applyTargetConfig(cc *collector.ClientConfig, target config.Target) error {
+	apiKey, password, err := target.Credentials(exporterConfig.Secrets())
+	if err != nil {
+		return err
+	}
+	if apiKey != "" {
+		cc.APIKey, cc.Login = apiKey, nil
+	} else if target.Username != "" {
+		cc.APIKey, cc.Login = "", &collector.Login{Username: target.Username, Password: password}
+	}
+	if target.TLS.CAFile != "" {
+		cc.TLS.CAFile = target.TLS.CAFile
+	}
+	if target.TLS.CertFile != "" {
+		cc.TLS.CertFile = target.TLS.CertFile
+	}
+	if target.TLS.KeyFile != "" {
+		cc.TLS.KeyFile = target.TLS.KeyFile
+	}
+	if target.TLS.ServerName != "" {
+		cc.TLS.ServerName = target.TLS.ServerName
+	}
+	if target.TLS.InsecureSkipVerify != nil {
+		cc.TLS.InsecureSkipVerify = *target.TLS.InsecureSkipVerify
+	}
+	if target.BasePath != "" {
+		cc.BasePath = target.BasePath
+	}
+	for name, value := range target.Headers {
+		if cc.Headers == nil {
+			cc.Headers = make(map[string]string)
+		}
+		cc.Headers[name] = value
+	}
+	if target.Subscribe != nil {
+		cc.Subscribe = *target.Subscribe
+	}
+	if target.CacheTTL != nil {
+		cc.CacheTTL = *target.CacheTTL
+	}
+	return nil
+}
+
+const (
+	// loginUsernameHeader and loginPasswordHeader set the Moonraker user login
+	// for a scrape job, with http_headers in prometheus.yml.
+	loginUsernameHeader = "X-Moonraker-Username"
+	loginPasswordHeader = "X-Moonraker-Password"
+)
+
+// getCredentials returns the API key or user login to authenticate with Moonraker.
+// prometheus.yml authorization or login headers > command line arg > environment
+// variable. An API key takes precedence over a user login from the same source.
+func getCredentials(header http.Header) (string, *collector.Login, error) {
+	auth := header.Get("Authorization")
 	if auth != "" && strings.HasPrefix(auth, "APIKEY") {
-		apiKey = strings.Replace(auth, "APIKEY ", "", 1)
 		log.Debug("Using API key from prometheus.yml authorization configuration")
-	} else if *klipperApiKey != "" {
-		apiKey = *klipperApiKey
+		return strings.Replace(auth, "APIKEY ", "", 1), nil, nil
+	}
+	if username := header.Get(loginUsernameHeader); username != "" {
+		log.Debugf("Using user login from the prometheus.yml %s header", loginUsernameHeader)
+		return "", &collector.Login{Username: username, Password: header.Get(loginPasswordHeader)}, nil
+	}
+	// basic auth is for the exporter itself with a web config file, and is never
+	// used to log in to Moonraker
+	if len(auth) > 6 && strings.EqualFold(auth[:6], "Basic ") && *webConfigFile == "" {
+		log.Warnf("Ignoring the basic_auth credentials of the probe request, set the Moonraker user login with the %s and %s headers", loginUsernameHeader, loginPasswordHeader)
+	}
+	if *klipperApiKey != "" {
 		log.Debug("Using API key from -moonraker.apikey command line argument")
-	} else if apiKey = os.Getenv("MOONRAKER_APIKEY"); apiKey != "" {
+		return *klipperApiKey, nil, nil
+	}
+	if *klipperApiKeyFile != "" {
+		log.Debug("Using API key from -moonraker.apikey-file command line argument")
+		apiKey, err := exporterConfig.Secrets().Read(*klipperApiKeyFile)
+		return apiKey, nil, err
+	}
+	if *klipperUser != "" {
+		log.Debug("Using user login from -moonraker.username command line argument")
+		password, err := getPassword()
+		return "", &collector.Login{Username: *klipperUser, Password: password}, err
+	}
+	if apiKey := os.Getenv("MOONRAKER_APIKEY"); apiKey != "" {
 		log.Debug("Using API key from MOONRAKER_APIKEY environment variable")
-	} else {
-		log.Debug("API key not set")
+		return apiKey, nil, nil
+	}
+	if username := os.Getenv("MOONRAKER_USERNAME"); username != "" {
+		log.Debug("Using user login from MOONRAKER_USERNAME environment variable")
+		password, err := getPassword()
+		return "", &collector.Login{Username: username, Password: password}, err
+	}
+	log.Debug("API key not set")
+	return "", nil, nil
+}
+
+// getPassword returns the password for the command line or environment variable
+// user login. -moonraker.password-file > MOONRAKER_PASSWORD environment variable.
+func getPassword() (string, error) {
+	if *klipperPasswordFile != "" {
+		return exporterConfig.Secrets().Read(*klipperPasswordFile)
+	}
+	return os.Getenv("MOONRAKER_PASSWORD"), nil
+}
+
+// getTLSConfig returns the TLS settings for a probe. The server name can be
+// overridden per target with the `tls_server_name` parameter in prometheus.yml.
+// The certificate files and certificate verification are only set from the
+// command line or for named targets in the config file, since /probe requests
+// aren't authenticated unless -web.config.file is set.
+func getTLSConfig(query url.Values) collector.TLSConfig {
+	cfg := collector.TLSConfig{
+		CAFile:             *tlsCAFile,
+		CertFile:           *tlsCertFile,
+		KeyFile:            *tlsKeyFile,
+		ServerName:         *tlsServerName,
+		InsecureSkipVerify: *tlsInsecureSkipVerify,
+	}
+	if query.Has("tls_server_name") {
+		cfg.ServerName = query.Get("tls_server_name")
+	}
+	return cfg
+}
+
+// getHeaders parses the custom request headers from the repeatable `header`
+// parameter in prometheus.yml, each in the form `Name: value`.
+func getHeaders(query url.Values) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, header := range query["header"] {
+		name, value, found := strings.Cut(header, ":")
+		if !found || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid 'header' parameter %q, expected 'Name: value'", header)
+		}
+		headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// getMetricFilter parses the metric filter from the repeatable `include` and
+// `exclude` parameters in prometheus.yml, and the `filter_labels` parameter.
+// The include, exclude, and filter_labels options of a named target replace the
+// parameters when set.
+func getMetricFilter(query url.Values, target config.Target) (collector.MetricFilter, error) {
+	include, exclude := query["include"], query["exclude"]
+	if len(target.Include) > 0 || len(target.Exclude) > 0 {
+		include, exclude = target.Include, target.Exclude
+	}
+	filterLabels := false
+	if query.Has("filter_labels") {
+		var err error
+		if filterLabels, err = strconv.ParseBool(query.Get("filter_labels")); err != nil {
+			return collector.MetricFilter{}, fmt.Errorf("invalid 'filter_labels' parameter: %w", err)
+		}
+	}
+	if target.FilterLabels != nil {
+		filterLabels = *target.FilterLabels
+	}
+	return collector.ParseMetricFilter(include, exclude, filterLabels)
+}
+
+// probe is a single probe of a target, with the collector registered with the
+// registry serving the probe.
+type probe struct {
+	target    string
+	registry  *prometheus.Registry
+	collector *collector.Collector
+	cancel    context.CancelFunc
+}
+
+// probeError is an error building a probe, with the HTTP status of the /probe
+// response.
+type probeError struct {
+	status int
+	err    error
+}
+
+func (e *probeError) Error() string {
+	return e.err.Error()
+}
+
+// newProbe builds the collector for the target, modules, and settings of a probe
+// request from its query parameters and headers. The probe uses the context until
+// the scrape timeout. The returned error is a *probeError.
+func newProbe(ctx context.Context, query url.Values, header http.Header) (*probe, error) {
+	target := query.Get("target")
+	if len(query["target"]) != 1 || target == "" {
+		return nil, &probeError{http.StatusBadRequest, errors.New("'target' parameter must be specified once")}
+	}
+
+	// resolve named targets from the config file
+	cfg := exporterConfig.Get()
+	targetConfig, named := cfg.Target(target)
+	if !named && !targetAllowed(cfg, target) {
+		log.Warnf("Rejected probe of target %s not in the allowed targets", target)
+		probeRejected.Inc()
+		return nil, &probeError{http.StatusForbidden, fmt.Errorf("target %s is not allowed", target)}
+	}
+	address := target
+	if named {
+		address = targetConfig.Address
+		log.Debugf("Resolved target %s to %s", target, address)
+	}
+
+	// get `profile` and `modules` configuration passed from the prometheus.yml
+	profile, modules := query.Get("profile"), query["modules"]
+	if named && (targetConfig.Profile != "" || len(targetConfig.Modules) > 0) {
+		profile, modules = targetConfig.Profile, targetConfig.Modules
+	}
+	modules, err := cfg.Modules(profile, modules)
+	if err != nil {
+		return nil, &probeError{http.StatusBadRequest, err}
+	}
+	if len(modules) == 0 {
+		modules = collector.DefaultModules()
+	}
+	log.Infof("Starting metrics collection of %s for %s", modules, target)
+
+	apiKey, login, err := getCredentials(header)
+	if err != nil {
+		log.Errorf("Unable to read credentials for %s: %v", target, err)
+		return nil, &probeError{http.StatusInternalServerError, err}
+	}
+
+	tlsConfig := getTLSConfig(query)
+
+	headers, err := getHeaders(query)
+	if err != nil {
+		return nil, &probeError{http.StatusBadRequest, err}
+	}
+
+	filter, err := getMetricFilter(query, targetConfig)
+	if err != nil {
+		return nil, &probeError{http.StatusBadRequest, err}
+	}
+
+	subscribe := *subscribe
+	if query.Has("subscribe") {
+		if subscribe, err = strconv.ParseBool(query.Get("subscribe")); err != nil {
+			return nil, &probeError{http.StatusBadRequest, fmt.Errorf("invalid 'subscribe' parameter: %w", err)}
+		}
+	}
+
+	cacheTTL := *cacheTTL
+	if query.Has("cache_ttl") {
+		if cacheTTL, err = time.ParseDuration(query.Get("cache_ttl")); err != nil {
+			return nil, &probeError{http.StatusBadRequest, fmt.Errorf("invalid 'cache_ttl' parameter: %w", err)}
+		}
+		// the parameter isn't authenticated, so it can't pin responses for longer
+		cacheTTL = min(cacheTTL, *maxCacheTTL)
+	}
+
+	timeout, err := getTimeout(header, *timeoutOffset)
+	if err != nil {
+		return nil, &probeError{http.StatusBadRequest, err}
+	}
+	log.Debugf("Using probe timeout of %s for %s", timeout, target)
+
+	clientConfig := collector.ClientConfig{
+		APIKey:    apiKey,
+		Login:     login,
+		TLS:       tlsConfig,
+		BasePath:  query.Get("base_path"),
+		Headers:   headers,
+		Subscribe: subscribe,
+		CacheTTL:  cacheTTL,
+		Retries:   *retries,
+		CircuitBreaker: collector.CircuitBreakerConfig{
+			Failures: *cbFailures,
+			Cooldown: *cbCooldown,
+		},
+	}
+	if named {
+		if err := applyTargetConfig(&clientConfig, targetConfig); err != nil {
+			log.Errorf("Unable to read credentials for %s: %v", target, err)
+			return nil, &probeError{http.StatusInternalServerError, err}
+		}
 	}
 
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	registry := prometheus.NewRegistry()
-	c := collector.New(r.Context(), target, modules, apiKey)
+	c := collector.New(address,
+		collector.WithContext(ctx),
+		collector.WithModules(modules...),
+		collector.WithClientConfig(clientConfig),
+		collector.WithConstLabels(targetConfig.Labels),
+		collector.WithMetricFilter(filter),
+		collector.WithState(exporterState),
+		collector.WithLogger(log.StandardLogger()),
+	)
 	registry.MustRegister(c)
-	h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+	return &probe{target: target, registry: registry, collector: c, cancel: cancel}, nil
+}
+
+// status returns the outcome of the probe, after the registry has been gathered,
+// and records it for the status page.
+func (p *probe) status() collector.ProbeStatus {
+	status := p.collector.Status()
+	status.Target = collector.RedactTarget(p.target)
+	if !status.Time.IsZero() {
+		probeStatuses.record(status)
+	}
+	return status
+}
+
+func handler(w http.ResponseWriter, r *http.Request) {
+	p, err := newProbe(r.Context(), r.URL.Query(), r.Header)
+	if err != nil {
+		http.Error(w, err.Error(), err.(*probeError).status)
+		return
+	}
+	defer p.cancel()
+
+	h := promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{})
 	h.ServeHTTP(w, r)
+	p.status()
+}
+
+// reloadConfig reloads the config file, keeping the current config if the file
+// is invalid.
+func reloadConfig() error {
+	if err := exporterConfig.Reload(); err != nil {
+		log.Errorf("Failed to reload config, keeping the current config: %v", err)
+		return err
+	}
+	log.Infof("Reloaded %d targets from %s", len(exporterConfig.Get().Targets), exporterConfig.Filename())
+	return nil
+}
+
+func reloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "This endpoint requires a POST request", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := reloadConfig(); err != nil {
+		http.Error(w, fmt.Sprintf("failed to reload config: %v", err), http.StatusInternalServerError)
+	}
+}
+
+// slogLevel maps the logrus logging level to the slog level used by the
+// exporter-toolkit web server. Trace logs at debug level, and fatal and panic
+// at error level.
+func slogLevel(level log.Level) slog.Level {
+	switch {
+	case level >= log.DebugLevel:
+		return slog.LevelDebug
+	case level == log.InfoLevel:
+		return slog.LevelInfo
+	case level == log.WarnLevel:
+		return slog.LevelWarn
+	}
+	return slog.LevelError
 }
 
 func main() {
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "probe":
+			os.Exit(probeCommand(os.Args[2:], os.Stdout, os.Stderr))
+		case "metrics":
+			os.Exit(metricsCommand(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
 	if loggingLevelEnv, loggingLevelEnvSet := os.LookupEnv("LOGGING_LEVEL"); loggingLevelEnvSet {
 		*loggingLevel = loggingLevelEnv
 	}
@@ -73,10 +480,42 @@ func main() {
 	}
 	log.SetLevel(level)
 
+	if flagAllowlist, err = config.ParseTargetAllowlist(strings.Split(*allowedTargets, ",")); err != nil {
+		log.Fatal(err)
+	}
+	prometheus.MustRegister(probeRejected, exporterState)
+
+	exporterConfig = config.NewSafeConfig(*configFile, prometheus.DefaultRegisterer)
+	if err := exporterConfig.Reload(); err != nil {
+		log.Fatal(err)
+	}
+	if *configFile != "" {
+		log.Infof("Loaded %d targets from %s", len(exporterConfig.Get().Targets), *configFile)
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reloadConfig()
+		}
+	}()
+
 	http.Handle("/metrics", promhttp.Handler())
 	http.HandleFunc("/probe", func(w http.ResponseWriter, r *http.Request) {
 		handler(w, r)
 	})
+	http.HandleFunc("/-/reload", reloadHandler)
+	http.HandleFunc("/status.json", statusJSONHandler)
+	http.HandleFunc("/", statusHandler)
 	log.Infof("Beginning to serve on port %s", *listenAddress)
-	log.Fatal(http.ListenAndServe(*listenAddress, nil))
+	server := &http.Server{}
+	systemdSocket := false
+	webFlags := &web.FlagConfig{
+		WebListenAddresses: &[]string{*listenAddress},
+		WebSystemdSocket:   &systemdSocket,
+		WebConfigFile:      webConfigFile,
+	}
+	logger := slog.New(slog.NewTextHandler(log.StandardLogger().Out, &slog.HandlerOptions{Level: slogLevel(level)}))
+	log.Fatal(web.ListenAndServe(server, webFlags, logger))
 }
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+func TestSlogLevel(t *testing.T) {
+	tests := map[log.Level]slog.Level{
+		log.TraceLevel: slog.LevelDebug,
+		log.DebugLevel: slog.LevelDebug,
+		log.InfoLevel:  slog.LevelInfo,
+		log.WarnLevel:  slog.LevelWarn,
+		log.ErrorLevel: slog.LevelError,
+		log.FatalLevel: slog.LevelError,
+		log.PanicLevel: slog.LevelError,
+	}
+	for level, expected := range tests {
+		if actual := slogLevel(level); actual != expected {
+			t.Errorf("Expected slog level %v for %v, got %v", expected, level, actual)
+		}
+	}
+}
+
+// Test that the status store keeps the last probe of each target, and removes
+// the targets that are no longer probed
+func TestStatusStore(t *testing.T) {
+	now := time.Now()
+	store := newStatusStore()
+	store.record(collector.ProbeStatus{Target: "voron24", Time: now, Up: false})
+	store.record(collector.ProbeStatus{Target: "ender3", Time: now, Up: true})
+	store.record(collector.ProbeStatus{Target: "voron24", Time: now, Up: true})
+	store.record(collector.ProbeStatus{Target: "retired", Time: now.Add(-time.Hour), Up: true})
+
+	statuses := store.all()
+	if len(statuses) != 2 {
+		t.Fatalf("Expected 2 statuses, got %d", len(statuses))
+	}
+	if statuses[0].Target != "ender3" || statuses[1].Target != "voron24" || !statuses[1].Up {
+		t.Errorf("Expected latest statuses sorted by target, got %+v", statuses)
+	}
+}
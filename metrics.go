@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+// metricsCommand prints the catalog of every metric the exporter can emit on a
+// probe, with the type, help text, labels, and owning module, e.g.
+//
+//	prometheus-klipper-exporter metrics --format markdown
+//
+// Returns the exit code.
+func metricsCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("metrics", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "json", "Output format, one of json or markdown.")
+	module := fs.String("module", "", "Only list the metrics of the module.")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s metrics [--format json|markdown] [--module <module>]\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	catalog := []collector.MetricInfo{}
+	for _, m := range collector.Metrics() {
+		if *module == "" || m.Module == *module {
+			catalog = append(catalog, m)
+		}
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(catalog); err != nil {
+			fmt.Fprintf(stderr, "Failed to write metrics: %v\n", err)
+			return 1
+		}
+	case "markdown":
+		writeMetricsMarkdown(stdout, catalog)
+	default:
+		fmt.Fprintf(stderr, "Invalid format %q, expected json or markdown\n", *format)
+		return 2
+	}
+	return 0
+}
+
+// writeMetricsMarkdown writes the catalog as a Markdown table per module, in
+// the style of the docs/metrics pages.
+func writeMetricsMarkdown(w io.Writer, catalog []collector.MetricInfo) {
+	module := ""
+	for _, m := range catalog {
+		if m.Module != module {
+			if module != "" {
+				fmt.Fprintln(w)
+			}
+			module = m.Module
+			fmt.Fprintf(w, "## `%s`\n\n", module)
+			fmt.Fprintln(w, "| Metric | Type | Labels | Description |")
+			fmt.Fprintln(w, "|--------|------|--------|-------------|")
+		}
+		labels := make([]string, 0, len(m.Labels))
+		for _, label := range m.Labels {
+			labels = append(labels, "`"+label+"`")
+		}
+		metricType := strings.ToUpper(m.Type[:1]) + m.Type[1:]
+		help := strings.ReplaceAll(m.Help, "|", `\|`)
+		fmt.Fprintf(w, "| `%s` | %s | %s | %s |\n", m.Name, metricType, strings.Join(labels, ", "), help)
+	}
+}
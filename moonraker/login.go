@@ -0,0 +1,132 @@
+package moonraker
+
+// Moonraker user login authentication
+// https://moonraker.readthedocs.io/en/latest/external_api/authorization/
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin is how long before expiry the access token is refreshed.
+const tokenRefreshMargin = 5 * time.Minute
+
+type loginResult struct {
+	Username     string `json:"username"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+type refreshResult struct {
+	Username string `json:"username"`
+	Token    string `json:"token"`
+}
+
+// loginSession holds the tokens for the user of a client, so the client only
+// logs in when needed.
+type loginSession struct {
+	mu           sync.Mutex
+	token        string
+	refreshToken string
+	expiry       time.Time
+}
+
+// accessToken returns a valid access token, refreshing the token before it
+// expires or logging in again when there is no usable token.
+func (s *loginSession) accessToken(ctx context.Context, c *Client) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && (s.expiry.IsZero() || time.Until(s.expiry) > tokenRefreshMargin) {
+		return s.token, nil
+	}
+
+	login := c.config.Login
+	if s.refreshToken != "" {
+		var response result[refreshResult]
+		err := c.postWithoutToken(ctx, "/access/refresh_jwt", map[string]string{"refresh_token": s.refreshToken}, &response)
+		if err == nil {
+			c.config.Logger.Debugf("Refreshed access token for %s on %s", login.Username, c.target)
+			s.setToken(response.Result.Token)
+			return s.token, nil
+		}
+		c.config.Logger.Debugf("Unable to refresh access token for %s on %s, logging in again: %v", login.Username, c.target, err)
+	}
+
+	source := login.Source
+	if source == "" {
+		source = "moonraker"
+	}
+	var response result[loginResult]
+	err := c.postWithoutToken(ctx, "/access/login", map[string]string{
+		"username": login.Username,
+		"password": login.Password,
+		"source":   source,
+	}, &response)
+	if err != nil {
+		s.token, s.refreshToken = "", ""
+		return "", fmt.Errorf("unable to login to %s as %s: %w", c.target, login.Username, err)
+	}
+	c.config.Logger.Debugf("Logged in to %s as %s", c.target, login.Username)
+	s.refreshToken = response.Result.RefreshToken
+	s.setToken(response.Result.Token)
+	return s.token, nil
+}
+
+// invalidate discards the tokens after the access token was rejected so the next
+// request logs in again, unless it has already been replaced by a concurrent request.
+func (s *loginSession) invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == token {
+		s.token, s.refreshToken = "", ""
+	}
+}
+
+func (s *loginSession) setToken(token string) {
+	s.token = token
+	s.expiry = tokenExpiry(token)
+}
+
+// tokenExpiry reads the `exp` claim of the JSON Web Token. The signature is not
+// verified, the expiry is only used to schedule the refresh. A zero time is
+// returned if the expiry can't be read, in which case the token is used until
+// Moonraker rejects it.
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}
+	}
+	var claims struct {
+		Exp float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(claims.Exp), 0)
+}
+
+// postWithoutToken POSTs to the Moonraker access API without a bearer token.
+func (c *Client) postWithoutToken(ctx context.Context, urlPath string, body interface{}, response interface{}) error {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("unable to marshal request body: %w", err)
+	}
+	data, err := c.doHTTPRequest(ctx, "POST", urlPath, jsonBody, "")
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, response); err != nil {
+		return fmt.Errorf("unable to unmarshal response to %T: %w", response, err)
+	}
+	return nil
+}
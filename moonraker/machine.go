@@ -0,0 +1,125 @@
+package moonraker
+
+import (
+	"context"
+	"net/url"
+)
+
+// ProcStats is the response of /machine/proc_stats.
+// https://moonraker.readthedocs.io/en/latest/external_api/machine/#get-moonraker-process-stats
+type ProcStats struct {
+	MoonrakerStats []ProcessStats          `json:"moonraker_stats"`
+	CpuTemp        float64                 `json:"cpu_temp"`
+	Network        map[string]NetworkStats `json:"network"`
+	SystemCpuUsage SystemCpuUsage          `json:"system_cpu_usage"`
+	SystemMemory   SystemMemory            `json:"system_memory"`
+	SystemUptime   float64                 `json:"system_uptime"`
+	// the misspelled key is the one sent by Moonraker
+	WebsocketConnections int            `json:"websocket_connectsions"`
+	ThrottledState       ThrottledState `json:"throttled_state"`
+}
+
+type ProcessStats struct {
+	Time     float64 `json:"time"`
+	CpuUsage float64 `json:"cpu_usage"`
+	Memory   int     `json:"memory"`
+	MemUnits string  `json:"mem_units"`
+}
+
+type NetworkStats struct {
+	RxBytes   int64   `json:"rx_bytes"`
+	TxBytes   int64   `json:"tx_bytes"`
+	RxPackets int64   `json:"rx_packets"`
+	TxPackets int64   `json:"tx_packets"`
+	RxErrs    int     `json:"rx_errs"`
+	TxErrs    int     `json:"tx_errs"`
+	RxDrop    int     `json:"rx_drop"`
+	TxDrop    int     `json:"tx_drop"`
+	Bandwidth float64 `json:"bandwidth"`
+}
+
+type SystemCpuUsage struct {
+	Cpu  float64 `json:"cpu"`
+	Cpu0 float64 `json:"cpu0"`
+	Cpu1 float64 `json:"cpu1"`
+	Cpu2 float64 `json:"cpu2"`
+	Cpu3 float64 `json:"cpu3"`
+}
+
+type SystemMemory struct {
+	Total     int `json:"total"`
+	Available int `json:"available"`
+	Used      int `json:"used"`
+}
+
+type ThrottledState struct {
+	Bits  float64  `json:"bits"`
+	Flags []string `json:"flags"`
+}
+
+// ProcStats returns the Moonraker process and host system statistics.
+func (c *Client) ProcStats(ctx context.Context) (*ProcStats, error) {
+	return getResult[ProcStats](ctx, c, "/machine/proc_stats")
+}
+
+// SystemInfo is the `system_info` of the /machine/system_info response.
+// https://moonraker.readthedocs.io/en/latest/external_api/machine/#get-system-info
+type SystemInfo struct {
+	CpuInfo struct {
+		CpuCount    int    `json:"cpu_count"`
+		TotalMemory int    `json:"total_memory"`
+		MemoryUnits string `json:"memory_units"`
+	} `json:"cpu_info"`
+	AvailableServices []string                `json:"available_services"`
+	ServiceState      map[string]ServiceState `json:"service_state"`
+}
+
+type ServiceState struct {
+	ActiveState string `json:"active_state"`
+	SubState    string `json:"sub_state"`
+}
+
+// SystemInfo returns the information about the host system.
+func (c *Client) SystemInfo(ctx context.Context) (*SystemInfo, error) {
+	info, err := getResult[struct {
+		SystemInfo SystemInfo `json:"system_info"`
+	}](ctx, c, "/machine/system_info")
+	if err != nil {
+		return nil, err
+	}
+	return &info.SystemInfo, nil
+}
+
+// PowerDevice is a device configured in the Moonraker `[power]` sections.
+// https://moonraker.readthedocs.io/en/latest/external_api/devices/#get-device-list
+type PowerDevice struct {
+	Device              string `json:"device"`
+	Status              string `json:"status"`
+	LockedWhilePrinting bool   `json:"locked_while_printing"`
+	Type                string `json:"type"`
+}
+
+// PowerDevices returns the configured power devices.
+func (c *Client) PowerDevices(ctx context.Context) ([]PowerDevice, error) {
+	devices, err := getResult[struct {
+		Devices []PowerDevice `json:"devices"`
+	}](ctx, c, "/machine/device_power/devices")
+	if err != nil {
+		return nil, err
+	}
+	return devices.Devices, nil
+}
+
+// PowerDeviceStatus returns the status, e.g. `on` or `off`, of the power devices
+// by device name.
+func (c *Client) PowerDeviceStatus(ctx context.Context, devices ...string) (map[string]string, error) {
+	urlPath := "/machine/device_power/status"
+	if len(devices) > 0 {
+		urlPath += "?" + url.Values{"device": devices}.Encode()
+	}
+	status, err := getResult[map[string]string](ctx, c, urlPath)
+	if err != nil {
+		return nil, err
+	}
+	return *status, nil
+}
@@ -0,0 +1,89 @@
+package moonraker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Objects selects printer objects and their attributes, by object name, e.g.
+// `toolhead` or `temperature_sensor chamber`. A nil attribute list selects all
+// the attributes of the object.
+type Objects map[string][]string
+
+// Encode returns the objects as the query string of /printer/objects/query, in
+// sorted order.
+func (o Objects) Encode() string {
+	names := make([]string, 0, len(o))
+	for name := range o {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var query strings.Builder
+	for _, name := range names {
+		if query.Len() > 0 {
+			query.WriteByte('&')
+		}
+		query.WriteString(strings.ReplaceAll(url.QueryEscape(name), "+", "%20"))
+		if attributes := o[name]; attributes != nil {
+			escaped := make([]string, len(attributes))
+			for i, attribute := range attributes {
+				escaped[i] = url.QueryEscape(attribute)
+			}
+			query.WriteByte('=')
+			query.WriteString(strings.Join(escaped, ","))
+		}
+	}
+	return query.String()
+}
+
+// PrinterObjectsList returns the names of the printer objects available on the
+// printer.
+// https://moonraker.readthedocs.io/en/latest/external_api/printer/#list-loaded-printer-objects
+func (c *Client) PrinterObjectsList(ctx context.Context) ([]string, error) {
+	list, err := getResult[struct {
+		Objects []string `json:"objects"`
+	}](ctx, c, "/printer/objects/list")
+	if err != nil {
+		return nil, err
+	}
+	return list.Objects, nil
+}
+
+// QueryPrinterObjects queries the status of the printer objects and
+// JSON-unmarshals the status, an object keyed by object name, into status.
+// https://moonraker.readthedocs.io/en/latest/external_api/printer/#query-printer-object-status
+func (c *Client) QueryPrinterObjects(ctx context.Context, objects Objects, status interface{}) error {
+	urlPath := "/printer/objects/query"
+	if len(objects) > 0 {
+		urlPath += "?" + objects.Encode()
+	}
+	response, err := getResult[struct {
+		Status json.RawMessage `json:"status"`
+	}](ctx, c, urlPath)
+	if err != nil {
+		return err
+	}
+	if response.Status == nil {
+		return nil
+	}
+	if err := json.Unmarshal(response.Status, status); err != nil {
+		return fmt.Errorf("unable to unmarshal printer object status to %T: %w", status, err)
+	}
+	return nil
+}
+
+// QueryEndstops returns the state, `open` or `TRIGGERED`, of the endstops by
+// endstop name.
+// https://moonraker.readthedocs.io/en/latest/external_api/printer/#query-endstops
+func (c *Client) QueryEndstops(ctx context.Context) (map[string]string, error) {
+	endstops, err := getResult[map[string]string](ctx, c, "/printer/query_endstops/status")
+	if err != nil {
+		return nil, err
+	}
+	return *endstops, nil
+}
@@ -0,0 +1,376 @@
+package moonraker
+
+// JSON-RPC transport for Moonraker
+// https://moonraker.readthedocs.io/en/latest/external_api/jsonrpc_notifications/
+// https://moonraker.readthedocs.io/en/latest/configuration/#unix-socket-connection
+//
+// Moonraker's `printer_data/comms/moonraker.sock` speaks JSON-RPC 2.0 rather than
+// HTTP, with each message terminated by an ETX (0x03) byte. To keep a single code
+// path for all endpoints, HTTP API paths are translated to the equivalent JSON-RPC
+// method (`/server/info` -> `server.info`) and the query string to the params.
+// JSON-RPC responses carry the same `result` member as the HTTP responses, so the
+// same response types are used for both.
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const jsonRPCTerminator = 0x03
+
+// websocketHandshakeTimeout bounds the websocket handshake when the context
+// has no deadline.
+const websocketHandshakeTimeout = 10 * time.Second
+
+// rpcMethodOverrides lists the HTTP endpoints whose JSON-RPC method name does not
+// follow the path to dotted name convention.
+var rpcMethodOverrides = map[string]string{
+	"/server/files/directory": "server.files.get_directory",
+}
+
+type jsonRPCRequest struct {
+	JSONRPC string                 `json:"jsonrpc"`
+	Method  string                 `json:"method"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+	ID      int64                  `json:"id"`
+}
+
+// RPCError is the error of a JSON-RPC response.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// Message is a JSON-RPC response or notification received from Moonraker.
+type Message struct {
+	ID     *int64          `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *RPCError       `json:"error"`
+}
+
+// unixSocketPath returns the socket path for `unix://` targets.
+func (c *Client) unixSocketPath() (string, bool) {
+	path, found := strings.CutPrefix(c.target, "unix://")
+	return path, found
+}
+
+// rpcRequestForPath translates a Moonraker HTTP API path, including the query
+// string, and optional JSON body to the equivalent JSON-RPC method and params.
+func rpcRequestForPath(urlPath string, body []byte) (string, map[string]interface{}, error) {
+	path, rawQuery, _ := strings.Cut(urlPath, "?")
+	method, ok := rpcMethodOverrides[path]
+	if !ok {
+		method = strings.ReplaceAll(strings.Trim(path, "/"), "/", ".")
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to parse query for %s: %w", path, err)
+	}
+
+	params := make(map[string]interface{})
+	if path == "/printer/objects/query" {
+		// objects are passed as {"object name": ["attr", ...]} or null for all attributes
+		objects := make(map[string]interface{})
+		for name, values := range query {
+			var attributes interface{}
+			if value := values[len(values)-1]; value != "" {
+				attributes = strings.Split(value, ",")
+			}
+			objects[name] = attributes
+		}
+		params["objects"] = objects
+	} else {
+		for name, values := range query {
+			converted := make([]interface{}, len(values))
+			for i, value := range values {
+				converted[i] = convertQueryValue(value)
+			}
+			if len(converted) == 1 {
+				params[name] = converted[0]
+			} else {
+				params[name] = converted
+			}
+		}
+	}
+
+	if body != nil {
+		var bodyParams map[string]interface{}
+		if err := json.Unmarshal(body, &bodyParams); err != nil {
+			return "", nil, fmt.Errorf("unable to convert request body to params: %w", err)
+		}
+		for name, value := range bodyParams {
+			params[name] = value
+		}
+	}
+
+	return method, params, nil
+}
+
+// convertQueryValue converts numeric and boolean query values to their JSON types,
+// the same way Moonraker converts HTTP query arguments.
+func convertQueryValue(value string) interface{} {
+	var converted interface{}
+	if err := json.Unmarshal([]byte(value), &converted); err == nil {
+		switch converted.(type) {
+		case float64, bool:
+			return converted
+		}
+	}
+	return value
+}
+
+// rpcConn is a JSON-RPC message connection to Moonraker.
+type rpcConn interface {
+	readMessage() ([]byte, error)
+	writeMessage(message []byte) error
+	Close() error
+}
+
+// unixSocketConn frames JSON-RPC messages on the Moonraker unix socket.
+type unixSocketConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func dialUnixSocket(ctx context.Context, socketPath string) (*unixSocketConn, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to unix socket %s: %w", socketPath, err)
+	}
+	return &unixSocketConn{Conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+func (u *unixSocketConn) readMessage() ([]byte, error) {
+	message, err := u.reader.ReadBytes(jsonRPCTerminator)
+	if err != nil {
+		return nil, err
+	}
+	return message[:len(message)-1], nil
+}
+
+func (u *unixSocketConn) writeMessage(message []byte) error {
+	_, err := u.Write(append(message, jsonRPCTerminator))
+	return err
+}
+
+type websocketConn struct {
+	*websocket.Conn
+}
+
+func (w websocketConn) readMessage() ([]byte, error) {
+	_, message, err := w.ReadMessage()
+	return message, err
+}
+
+func (w websocketConn) writeMessage(message []byte) error {
+	return w.WriteMessage(websocket.TextMessage, message)
+}
+
+func (c *Client) sendRPC(conn rpcConn, method string, params map[string]interface{}) (int64, error) {
+	id := c.state.rpcRequestID.Add(1)
+	request, err := json.Marshal(jsonRPCRequest{JSONRPC: "2.0", Method: method, Params: params, ID: id})
+	if err != nil {
+		return 0, fmt.Errorf("unable to marshal JSON-RPC request: %w", err)
+	}
+	if err := conn.writeMessage(request); err != nil {
+		return 0, fmt.Errorf("unable to send JSON-RPC request %s: %w", method, err)
+	}
+	return id, nil
+}
+
+// doUnixSocketRequest sends a JSON-RPC request over the Moonraker unix socket and
+// returns the matching response. Notifications received while waiting for
+// the response are ignored.
+func (c *Client) doUnixSocketRequest(ctx context.Context, socketPath, urlPath string, body []byte) ([]byte, error) {
+	method, params, err := rpcRequestForPath(urlPath, body)
+	if err != nil {
+		return nil, err
+	}
+	c.config.Logger.Debugf("Requesting %s from unix://%s", method, socketPath)
+
+	conn, err := dialUnixSocket(ctx, socketPath)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// unblock reads and writes when the context is cancelled
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	id, err := c.sendRPC(conn, method, params)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	for {
+		data, err := conn.readMessage()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read JSON-RPC response for %s: %w", method, contextError(ctx, err))
+		}
+
+		var message Message
+		if err := json.Unmarshal(data, &message); err != nil {
+			return nil, fmt.Errorf("unable to parse JSON-RPC message: %w", err)
+		}
+		if message.ID == nil || *message.ID != id {
+			continue
+		}
+		if message.Error != nil {
+			return nil, fmt.Errorf("unexpected error for %s: %d %s", method, message.Error.Code, message.Error.Message)
+		}
+		return data, nil
+	}
+}
+
+// contextError prefers the context error over the resulting connection error when
+// the connection was closed because the context was cancelled.
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// Conn is a persistent JSON-RPC connection to Moonraker, over the `/websocket`
+// endpoint, or the unix socket for `unix://` targets, used to receive
+// notifications such as printer object status updates.
+type Conn struct {
+	client *Client
+	conn   rpcConn
+}
+
+// Connect opens a JSON-RPC connection to Moonraker. The context bounds
+// connecting, the connection stays open until it is closed.
+func (c *Client) Connect(ctx context.Context) (*Conn, error) {
+	if socketPath, ok := c.unixSocketPath(); ok {
+		conn, err := dialUnixSocket(ctx, socketPath)
+		if err != nil {
+			return nil, err
+		}
+		return &Conn{client: c, conn: conn}, nil
+	}
+
+	client, err := c.httpClient()
+	if err != nil {
+		return nil, fmt.Errorf("unable to create HTTP client for %s: %w", c.target, err)
+	}
+	req, err := c.newRequest(ctx, "GET", "/websocket", nil)
+	if err != nil {
+		return nil, err
+	}
+	header := req.Header.Clone()
+	if req.Host != "" {
+		header.Set("Host", req.Host)
+	}
+	wsURL := *req.URL
+	wsURL.Scheme = strings.Replace(wsURL.Scheme, "http", "ws", 1)
+
+	dialer := websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: websocketHandshakeTimeout,
+	}
+	if transport, ok := client.Transport.(*http.Transport); ok {
+		dialer.TLSClientConfig = transport.TLSClientConfig
+	}
+	conn, res, err := dialer.DialContext(ctx, wsURL.String(), header)
+	if err != nil {
+		if res != nil {
+			return nil, fmt.Errorf("unable to connect to %s: %d %s", wsURL.Redacted(), res.StatusCode, res.Status)
+		}
+		return nil, fmt.Errorf("unable to connect to %s: %w", wsURL.Redacted(), err)
+	}
+	return &Conn{client: c, conn: websocketConn{conn}}, nil
+}
+
+// Identity identifies a client to Moonraker.
+// https://moonraker.readthedocs.io/en/latest/external_api/server/#identify-connection
+type Identity struct {
+	ClientName string
+	Version    string
+	Type       string
+	URL        string
+}
+
+// Identify identifies the connection to Moonraker, authenticating it with the
+// API key or access token of the client when set. Messages received before the
+// response are discarded.
+func (c *Conn) Identify(ctx context.Context, identity Identity) error {
+	params := map[string]interface{}{
+		"client_name": identity.ClientName,
+		"version":     identity.Version,
+		"type":        identity.Type,
+		"url":         identity.URL,
+	}
+	if c.client.config.APIKey != "" {
+		params["api_key"] = c.client.config.APIKey
+	}
+	if c.client.config.Login != nil {
+		token, err := c.client.state.session.accessToken(ctx, c.client)
+		if err != nil {
+			return err
+		}
+		params["access_token"] = token
+	}
+
+	id, err := c.Send("server.connection.identify", params)
+	if err != nil {
+		return err
+	}
+	for {
+		message, err := c.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("unable to read identify response: %w", err)
+		}
+		if message.ID == nil || *message.ID != id {
+			continue
+		}
+		if message.Error != nil {
+			return fmt.Errorf("unable to identify connection: %d %s", message.Error.Code, message.Error.Message)
+		}
+		return nil
+	}
+}
+
+// Send sends a JSON-RPC request and returns the request id, to match the
+// response read with ReadMessage.
+func (c *Conn) Send(method string, params map[string]interface{}) (int64, error) {
+	return c.client.sendRPC(c.conn, method, params)
+}
+
+// SubscribePrinterObjects requests a subscription to the printer objects, and
+// returns the request id. The response holds the initial status, and status
+// updates are received as `notify_status_update` notifications.
+func (c *Conn) SubscribePrinterObjects(objects Objects) (int64, error) {
+	return c.Send("printer.objects.subscribe", map[string]interface{}{"objects": objects})
+}
+
+// ReadMessage reads the next response or notification.
+func (c *Conn) ReadMessage() (*Message, error) {
+	data, err := c.conn.readMessage()
+	if err != nil {
+		return nil, err
+	}
+	var message Message
+	if err := json.Unmarshal(data, &message); err != nil {
+		return nil, fmt.Errorf("unable to parse JSON-RPC message: %w", err)
+	}
+	return &message, nil
+}
+
+// Close closes the connection, unblocking any ReadMessage call.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
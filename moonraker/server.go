@@ -0,0 +1,237 @@
+package moonraker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// ServerInfo is the response of /server/info.
+// https://moonraker.readthedocs.io/en/latest/external_api/server/#query-server-info
+type ServerInfo struct {
+	KlippyConnected  bool     `json:"klippy_connected"`
+	KlippyState      string   `json:"klippy_state"`
+	Components       []string `json:"components"`
+	FailedComponents []string `json:"failed_components"`
+	MoonrakerVersion string   `json:"moonraker_version"`
+	APIVersion       []int    `json:"api_version"`
+}
+
+// ServerInfo returns the Moonraker server and Klippy state.
+func (c *Client) ServerInfo(ctx context.Context) (*ServerInfo, error) {
+	return getResult[ServerInfo](ctx, c, "/server/info")
+}
+
+// JobQueueStatus is the response of /server/job_queue/status.
+// https://moonraker.readthedocs.io/en/latest/external_api/job_queue/#retrieve-the-job-queue-status
+type JobQueueStatus struct {
+	QueuedJobs []QueuedJob `json:"queued_jobs"`
+	QueueState string      `json:"queue_state"`
+}
+
+type QueuedJob struct {
+	Filename    string  `json:"filename"`
+	JobID       string  `json:"job_id"`
+	TimeAdded   float64 `json:"time_added"`
+	TimeInQueue float64 `json:"time_in_queue"`
+}
+
+// JobQueueStatus returns the jobs in the job queue and the queue state.
+func (c *Client) JobQueueStatus(ctx context.Context) (*JobQueueStatus, error) {
+	return getResult[JobQueueStatus](ctx, c, "/server/job_queue/status")
+}
+
+// JobTotals is the `job_totals` of the /server/history/totals response.
+// https://moonraker.readthedocs.io/en/latest/external_api/history/#get-job-totals
+type JobTotals struct {
+	Jobs         float64 `json:"total_jobs"`
+	TotalTime    float64 `json:"total_time"`
+	PrintTime    float64 `json:"total_print_time"`
+	FilamentUsed float64 `json:"total_filament_used"`
+	LongestJob   float64 `json:"longest_job"`
+	LongestPrint float64 `json:"longest_print"`
+}
+
+// HistoryTotals returns the totals of all jobs in the job history.
+func (c *Client) HistoryTotals(ctx context.Context) (*JobTotals, error) {
+	totals, err := getResult[struct {
+		JobTotals JobTotals `json:"job_totals"`
+	}](ctx, c, "/server/history/totals")
+	if err != nil {
+		return nil, err
+	}
+	return &totals.JobTotals, nil
+}
+
+// HistoryListOptions selects the jobs returned by HistoryList. Zero values are
+// not sent, so Moonraker's defaults apply.
+type HistoryListOptions struct {
+	Limit int
+	Start int
+	Since float64
+	// Order is `asc` or `desc`.
+	Order string
+}
+
+// HistoryList is the response of /server/history/list.
+// https://moonraker.readthedocs.io/en/latest/external_api/history/#get-job-list
+type HistoryList struct {
+	Count int          `json:"count"`
+	Jobs  []HistoryJob `json:"jobs"`
+}
+
+type HistoryJob struct {
+	EndTime       float64      `json:"end_time"`
+	FilamentUsed  float64      `json:"filament_used"`
+	Filename      string       `json:"filename"`
+	Metadata      FileMetadata `json:"metadata"`
+	PrintDuration float64      `json:"print_duration"`
+	Status        string       `json:"status"`
+	StartTime     float64      `json:"start_time"`
+	TotalDuration float64      `json:"total_duration"`
+	JobID         string       `json:"job_id"`
+	Exists        bool         `json:"exists"`
+}
+
+type FileMetadata struct {
+	Size               int         `json:"size"`
+	Modified           float64     `json:"modified"`
+	Slicer             string      `json:"slicer"`
+	SlicerVersion      string      `json:"slicer_version"`
+	LayerHeight        float64     `json:"layer_height"`
+	FirstLayerHeight   float64     `json:"first_layer_height"`
+	ObjectHeight       float64     `json:"object_height"`
+	FilamentTotal      float64     `json:"filament_total"`
+	EstimatedTime      float64     `json:"estimated_time"`
+	Thumbnails         []Thumbnail `json:"thumbnails"`
+	FirstLayerBedTemp  float64     `json:"first_layer_bed_temp"`
+	FirstLayerExtrTemp float64     `json:"first_layer_extr_temp"`
+	GcodeStartByte     int         `json:"gcode_start_byte"`
+	GcodeEndByte       int         `json:"gcode_end_byte"`
+}
+
+type Thumbnail struct {
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Size         int    `json:"size"`
+	RelativePath string `json:"relative_path"`
+}
+
+// HistoryList returns the jobs in the job history.
+func (c *Client) HistoryList(ctx context.Context, options HistoryListOptions) (*HistoryList, error) {
+	query := ""
+	if options.Limit != 0 {
+		query += "&limit=" + strconv.Itoa(options.Limit)
+	}
+	if options.Start != 0 {
+		query += "&start=" + strconv.Itoa(options.Start)
+	}
+	if options.Since != 0 {
+		query += "&since=" + strconv.FormatFloat(options.Since, 'f', -1, 64)
+	}
+	if options.Order != "" {
+		query += "&order=" + url.QueryEscape(options.Order)
+	}
+	urlPath := "/server/history/list"
+	if query != "" {
+		urlPath += "?" + query[1:]
+	}
+	return getResult[HistoryList](ctx, c, urlPath)
+}
+
+// DirectoryInfo is the response of /server/files/directory.
+// https://moonraker.readthedocs.io/en/latest/external_api/file_manager/#get-directory-information
+type DirectoryInfo struct {
+	DiskUsage struct {
+		Total int64 `json:"total"`
+		Used  int64 `json:"used"`
+		Free  int64 `json:"free"`
+	} `json:"disk_usage"`
+}
+
+// Directory returns the information of a directory managed by Moonraker, e.g.
+// `gcodes`, including the disk usage of its file system.
+func (c *Client) Directory(ctx context.Context, path string, extended bool) (*DirectoryInfo, error) {
+	urlPath := "/server/files/directory?" + url.Values{"path": {path}}.Encode() + "&extended=" + strconv.FormatBool(extended)
+	return getResult[DirectoryInfo](ctx, c, urlPath)
+}
+
+// SpoolmanStatus is the response of /server/spoolman/status.
+// https://moonraker.readthedocs.io/en/latest/external_api/integrations/#spoolman
+type SpoolmanStatus struct {
+	SpoolmanConnected bool `json:"spoolman_connected"`
+	PendingReports    []struct {
+		SpoolID      int     `json:"spool_id"`
+		FilamentUsed float64 `json:"filament_used"`
+	} `json:"pending_reports"`
+	SpoolID *int `json:"spool_id"`
+}
+
+// SpoolmanStatus returns the state of the Spoolman connection and the active spool.
+func (c *Client) SpoolmanStatus(ctx context.Context) (*SpoolmanStatus, error) {
+	return getResult[SpoolmanStatus](ctx, c, "/server/spoolman/status")
+}
+
+// SpoolmanProxyRequest is the request body for POST /server/spoolman/proxy.
+type SpoolmanProxyRequest struct {
+	RequestMethod string      `json:"request_method"`
+	Path          string      `json:"path"`
+	Query         *string     `json:"query"`
+	Body          interface{} `json:"body"`
+	UseV2Response bool        `json:"use_v2_response"`
+}
+
+// spoolmanProxyV2Result is the v2 proxy result inside the result envelope
+type spoolmanProxyV2Result struct {
+	Response        json.RawMessage `json:"response"`
+	ResponseHeaders *struct {
+		ContentType string `json:"Content-Type"`
+		XTotalCount string `json:"X-Total-Count"`
+	} `json:"response_headers"`
+	Error *struct {
+		StatusCode int    `json:"status_code"`
+		Message    string `json:"message"`
+	} `json:"error"`
+}
+
+// SpoolmanProxy sends a request to the Spoolman API through the Moonraker
+// Spoolman proxy and returns the Spoolman response, or nil if Spoolman returned
+// no content. The result of Moonraker versions without the v2 response format is
+// the Spoolman response itself:
+//
+//	v2: {"result": {"response": [...], "error": null}}
+//	v1: {"result": [...]}  (rare, older Moonraker)
+func (c *Client) SpoolmanProxy(ctx context.Context, request SpoolmanProxyRequest) (json.RawMessage, error) {
+	var envelope result[json.RawMessage]
+	if err := c.Post(ctx, "/server/spoolman/proxy", request, &envelope); err != nil {
+		return nil, err
+	}
+	if len(envelope.Result) == 0 {
+		return nil, fmt.Errorf("empty result from Spoolman proxy")
+	}
+
+	switch envelope.Result[0] {
+	case '[':
+		return envelope.Result, nil
+	case '{':
+		var v2Result spoolmanProxyV2Result
+		if err := json.Unmarshal(envelope.Result, &v2Result); err != nil {
+			return nil, fmt.Errorf("unable to parse Spoolman proxy result: %w", err)
+		}
+		if v2Result.Error != nil {
+			return nil, fmt.Errorf("spoolman proxy error (status %d): %s", v2Result.Error.StatusCode, v2Result.Error.Message)
+		}
+		if v2Result.Response == nil || string(v2Result.Response) == "null" {
+			return nil, nil
+		}
+		return v2Result.Response, nil
+	default:
+		snippet := string(envelope.Result)
+		if len(snippet) > 200 {
+			snippet = snippet[:200] + "..."
+		}
+		return nil, fmt.Errorf("unexpected Spoolman proxy result format: %s", snippet)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+	"github.com/scross01/prometheus-klipper-exporter/config"
+)
+
+// Exit codes of the probe command
+const (
+	probeExitSuccess      = 0
+	probeExitModuleFailed = 1
+	probeExitError        = 2
+)
+
+// probeSample is a single series of a metric in the probe command JSON output.
+// The value is a string for NaN and infinite values.
+type probeSample struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  any               `json:"value"`
+}
+
+// probeMetric is a metric family in the probe command JSON output.
+type probeMetric struct {
+	Name    string        `json:"name"`
+	Type    string        `json:"type"`
+	Help    string        `json:"help"`
+	Samples []probeSample `json:"samples"`
+}
+
+// probeOutput is the probe command JSON output.
+type probeOutput struct {
+	collector.ProbeStatus
+	Metrics []probeMetric `json:"metrics"`
+}
+
+// probeCommand runs a single probe of a target from the command line, e.g.
+//
+//	prometheus-klipper-exporter probe --target host:7125 --modules printer_objects,mmu
+//
+// and prints the metrics, followed by the outcome of each module. All command
+// line options of the exporter can be used to configure the probe. Returns the
+// exit code, non-zero if the probe or any module failed, or klipper_up is 0.
+func probeCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("probe", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	target := fs.String("target", "", "Moonraker target to probe, as used for the `target` parameter, or a named target from the config file.")
+	profile := fs.String("profile", "", "Module profile from the config file.")
+	modules := fs.String("modules", "", "Comma separated list of modules to collect, added to the profile, or -module to exclude a module. Uses the default modules when not set.")
+	include := fs.String("include", "", "Regular expression matching the metrics to collect.")
+	exclude := fs.String("exclude", "", "Regular expression matching the metrics not to collect.")
+	format := fs.String("format", "text", "Output format, one of text or json.")
+	timeout := fs.Duration("timeout", 10*time.Second, "Time budget for the probe.")
+	flag.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: %s probe --target <target> [--profile <profile>] [--modules <modules>] [--format text|json] [options]\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	// only log warnings and errors unless the logging level is set
+	*loggingLevel = "warning"
+	if loggingLevelEnv, loggingLevelEnvSet := os.LookupEnv("LOGGING_LEVEL"); loggingLevelEnvSet {
+		*loggingLevel = loggingLevelEnv
+	}
+	if err := fs.Parse(args); err != nil {
+		return probeExitError
+	}
+	if *target == "" {
+		fmt.Fprintln(stderr, "--target must be specified")
+		return probeExitError
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "Invalid format %q, expected text or json\n", *format)
+		return probeExitError
+	}
+
+	level, err := log.ParseLevel(*loggingLevel)
+	if err != nil {
+		fmt.Fprintf(stderr, "Invalid logging level '%s'\n", *loggingLevel)
+		return probeExitError
+	}
+	log.SetLevel(level)
+	log.SetOutput(stderr)
+
+	if flagAllowlist, err = config.ParseTargetAllowlist(strings.Split(*allowedTargets, ",")); err != nil {
+		fmt.Fprintln(stderr, err)
+		return probeExitError
+	}
+	exporterConfig = config.NewSafeConfig(*configFile, prometheus.NewRegistry())
+	if err := exporterConfig.Reload(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return probeExitError
+	}
+
+	// build the probe the same way as the /probe endpoint
+	query := url.Values{"target": {*target}}
+	if *profile != "" {
+		query.Set("profile", *profile)
+	}
+	if *include != "" {
+		query.Set("include", *include)
+	}
+	if *exclude != "" {
+		query.Set("exclude", *exclude)
+	}
+	for _, module := range strings.Split(*modules, ",") {
+		if module = strings.TrimSpace(module); module != "" {
+			query.Add("modules", module)
+		}
+	}
+	header := http.Header{}
+	header.Set("X-Prometheus-Scrape-Timeout-Seconds", strconv.FormatFloat(timeout.Seconds()+*timeoutOffset, 'f', -1, 64))
+	p, err := newProbe(context.Background(), query, header)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return probeExitError
+	}
+	defer p.cancel()
+
+	families, err := p.registry.Gather()
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to gather metrics: %v\n", err)
+		return probeExitError
+	}
+	status := p.status()
+
+	if *format == "json" {
+		err = writeProbeJSON(stdout, status, families)
+	} else {
+		err = writeProbeText(stdout, stderr, status, families)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to write metrics: %v\n", err)
+		return probeExitError
+	}
+
+	// klipper_up is 0 when the target is down or no module was collected
+	if !status.Up {
+		return probeExitModuleFailed
+	}
+	for _, module := range status.Modules {
+		if !module.Success {
+			return probeExitModuleFailed
+		}
+	}
+	return probeExitSuccess
+}
+
+// writeProbeText writes the metrics in the Prometheus text exposition format,
+// and the outcome of each module to stderr.
+func writeProbeText(stdout, stderr io.Writer, status collector.ProbeStatus, families []*dto.MetricFamily) error {
+	enc := expfmt.NewEncoder(stdout, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, family := range families {
+		if err := enc.Encode(family); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(stderr, "\nProbe of %s (%s) took %s\n", status.Target, status.Address, formatSeconds(status.Duration))
+	for _, module := range status.Modules {
+		result := "OK"
+		if !module.Success {
+			result = "FAILED: " + module.Error
+		}
+		fmt.Fprintf(stderr, "  %-16s %8s  %s\n", module.Module, formatSeconds(module.Duration), result)
+	}
+	return nil
+}
+
+// writeProbeJSON writes the outcome of each module and the metrics as JSON.
+func writeProbeJSON(stdout io.Writer, status collector.ProbeStatus, families []*dto.MetricFamily) error {
+	output := probeOutput{ProbeStatus: status, Metrics: make([]probeMetric, 0, len(families))}
+	for _, family := range families {
+		metric := probeMetric{
+			Name:    family.GetName(),
+			Type:    strings.ToLower(family.GetType().String()),
+			Help:    family.GetHelp(),
+			Samples: make([]probeSample, 0, len(family.GetMetric())),
+		}
+		for _, m := range family.GetMetric() {
+			sample := probeSample{}
+			if len(m.GetLabel()) > 0 {
+				sample.Labels = make(map[string]string, len(m.GetLabel()))
+				for _, label := range m.GetLabel() {
+					sample.Labels[label.GetName()] = label.GetValue()
+				}
+			}
+			var value float64
+			switch {
+			case m.Gauge != nil:
+				value = m.GetGauge().GetValue()
+			case m.Counter != nil:
+				value = m.GetCounter().GetValue()
+			case m.Untyped != nil:
+				value = m.GetUntyped().GetValue()
+			}
+			sample.Value = value
+			// JSON has no representation for NaN and infinity
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				sample.Value = strconv.FormatFloat(value, 'f', -1, 64)
+			}
+			metric.Samples = append(metric.Samples, sample)
+		}
+		output.Metrics = append(output.Metrics, metric)
+	}
+
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(output)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+	log "github.com/sirupsen/logrus"
+)
+
+// statusIdleTimeout is how long a target that is no longer probed is shown on
+// the status page.
+const statusIdleTimeout = 10 * time.Minute
+
+// statusStore keeps the status of the last probe of each target. Targets that
+// were not probed for statusIdleTimeout are removed.
+type statusStore struct {
+	mu       sync.Mutex
+	statuses map[string]collector.ProbeStatus
+}
+
+// newStatusStore returns an empty status store.
+func newStatusStore() *statusStore {
+	return &statusStore{statuses: map[string]collector.ProbeStatus{}}
+}
+
+// record stores the status of a probe, replacing the previous probe of the same
+// target.
+func (s *statusStore) record(status collector.ProbeStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictIdle()
+	s.statuses[status.Target] = status
+}
+
+// evictIdle removes the targets that were not probed for statusIdleTimeout.
+func (s *statusStore) evictIdle() {
+	for target, status := range s.statuses {
+		if time.Since(status.Time) > statusIdleTimeout {
+			delete(s.statuses, target)
+		}
+	}
+}
+
+// all returns the status of the last probe of each target, sorted by target.
+func (s *statusStore) all() []collector.ProbeStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictIdle()
+	statuses := make([]collector.ProbeStatus, 0, len(s.statuses))
+	for _, status := range s.statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Target < statuses[j].Target
+	})
+	return statuses
+}
+
+// probeStatuses keeps the outcome of the last probe of each target for the
+// status page.
+var probeStatuses = newStatusStore()
+
+// configuredTarget is a named target from the config file as shown on the
+// status page.
+type configuredTarget struct {
+	Name    string   `json:"name"`
+	Address string   `json:"address"`
+	Modules []string `json:"modules,omitempty"`
+}
+
+// exporterStatus is the content of the status page and /status.json.
+type exporterStatus struct {
+	Targets []configuredTarget      `json:"targets"`
+	Probes  []collector.ProbeStatus `json:"probes"`
+}
+
+func currentStatus() exporterStatus {
+	status := exporterStatus{
+		Targets: []configuredTarget{},
+		Probes:  probeStatuses.all(),
+	}
+	for name, target := range exporterConfig.Get().Targets {
+		status.Targets = append(status.Targets, configuredTarget{Name: name, Address: collector.RedactTarget(target.Address), Modules: target.Modules})
+	}
+	sort.Slice(status.Targets, func(i, j int) bool {
+		return status.Targets[i].Name < status.Targets[j].Name
+	})
+	return status
+}
+
+var statusTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"seconds": formatSeconds,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>Klipper Exporter</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.6em; text-align: left; vertical-align: top; }
+.ok { color: #080; }
+.failed { color: #c00; }
+</style>
+</head>
+<body>
+<h1>Klipper Exporter</h1>
+<ul>
+<li><a href="metrics">Metrics</a></li>
+<li><a href="status.json">Status (JSON)</a></li>
+</ul>
+<h2>Configured Targets</h2>
+{{- if .Targets}}
+<table>
+<tr><th>Target</th><th>Address</th><th>Modules</th></tr>
+{{- range .Targets}}
+<tr><td><a href="probe?target={{.Name}}">{{.Name}}</a></td><td>{{.Address}}</td><td>{{range $i, $m := .Modules}}{{if $i}}, {{end}}{{$m}}{{end}}</td></tr>
+{{- end}}
+</table>
+{{- else}}
+<p>No targets configured.</p>
+{{- end}}
+<h2>Last Probes</h2>
+{{- if .Probes}}
+<table>
+<tr><th>Target</th><th>Last Probe</th><th>Duration</th><th>Module</th><th>Status</th><th>Module Duration</th><th>Error</th></tr>
+{{- range .Probes}}
+{{- $probe := .}}
+{{- range $i, $m := .Modules}}
+<tr>
+{{- if not $i}}
+<td rowspan="{{len $probe.Modules}}"><a href="probe?target={{$probe.Target}}">{{$probe.Target}}</a>{{if ne $probe.Target $probe.Address}}<br>{{$probe.Address}}{{end}}</td>
+<td rowspan="{{len $probe.Modules}}">{{$probe.Time.Format "2006-01-02 15:04:05 MST"}}</td>
+<td rowspan="{{len $probe.Modules}}">{{seconds $probe.Duration}}</td>
+{{- end}}
+<td>{{$m.Module}}</td>
+<td>{{if $m.Success}}<span class="ok">OK</span>{{else}}<span class="failed">Failed</span>{{end}}</td>
+<td>{{seconds $m.Duration}}</td>
+<td>{{$m.Error}}</td>
+</tr>
+{{- end}}
+{{- end}}
+</table>
+{{- else}}
+<p>No targets probed yet.</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// formatSeconds formats a duration in seconds with millisecond precision.
+func formatSeconds(d float64) string {
+	return strconv.FormatFloat(d, 'f', 3, 64) + "s"
+}
+
+// statusHandler serves the status landing page.
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := statusTemplate.Execute(w, currentStatus()); err != nil {
+		log.Errorf("Failed to render status page: %v", err)
+	}
+}
+
+// statusJSONHandler serves the status page content as JSON.
+func statusJSONHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(currentStatus()); err != nil {
+		log.Errorf("Failed to encode status: %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+// cacheCounter returns the value of the exporter cache counter for the endpoint
+// from the state shared by the test collectors
+func cacheCounter(t *testing.T, name, endpoint string) float64 {
+	t.Helper()
+	return gatheredValue(t, name, "endpoint", endpoint)
+}
+
+// gatheredValue returns the value of the counter or gauge series with the label
+// value from the state shared by the test collectors
+func gatheredValue(t *testing.T, name, labelName, labelValue string) float64 {
+	t.Helper()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(testState)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == labelName && label.GetValue() == labelValue {
+					if m.GetGauge() != nil {
+						return m.GetGauge().GetValue()
+					}
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func newCountingServer(delay time.Duration, requests *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		time.Sleep(delay)
+		w.Write([]byte(jobQueueFixture))
+	}))
+}
+
+func TestConcurrentProbesShareRequests(t *testing.T) {
+	var requests atomic.Int32
+	server := newCountingServer(200*time.Millisecond, &requests)
+	defer server.Close()
+
+	hits := cacheCounter(t, "klipper_exporter_cache_hits_total", "/server/job_queue/status")
+	misses := cacheCounter(t, "klipper_exporter_cache_misses_total", "/server/job_queue/status")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := newCollector(context.Background(), server.URL, []string{"job_queue"}, collector.ClientConfig{})
+			if success := collectModuleSuccess(t, c); success["job_queue"] != 1 {
+				t.Errorf("Expected job_queue success, got %v", success["job_queue"])
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected concurrent probes to share 1 request, got %d", n)
+	}
+	if d := cacheCounter(t, "klipper_exporter_cache_hits_total", "/server/job_queue/status") - hits; d != 2 {
+		t.Errorf("Expected 2 cache hits, got %v", d)
+	}
+	if d := cacheCounter(t, "klipper_exporter_cache_misses_total", "/server/job_queue/status") - misses; d != 1 {
+		t.Errorf("Expected 1 cache miss, got %v", d)
+	}
+}
+
+// Test that a probe waiting on the shared request of a probe with a shorter
+// timeout requests again when the shared request is canceled
+func TestConcurrentProbesDifferentTimeouts(t *testing.T) {
+	var requests atomic.Int32
+	received := make(chan struct{}, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		received <- struct{}{}
+		select {
+		case <-time.After(300 * time.Millisecond):
+			w.Write([]byte(jobQueueFixture))
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+
+	probe := func(timeout time.Duration) float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		c := newCollector(ctx, server.URL, []string{"job_queue"}, collector.ClientConfig{})
+		return collectModuleSuccess(t, c)["job_queue"]
+	}
+
+	var wg sync.WaitGroup
+	var short float64
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		short = probe(100 * time.Millisecond)
+	}()
+	// the probe with the longer timeout waits on the request of the first probe
+	<-received
+	long := probe(2 * time.Second)
+	wg.Wait()
+
+	if short != 0 {
+		t.Errorf("Expected job_queue failure for the probe with the short timeout, got %v", short)
+	}
+	if long != 1 {
+		t.Errorf("Expected job_queue success for the probe with the long timeout, got %v", long)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Expected the request to be sent again after the shared request was canceled, got %d requests", n)
+	}
+}
+
+// Test that concurrent requests through the same Collector.Client each use their
+// own context
+func TestCollectorClientConcurrentContexts(t *testing.T) {
+	var requests atomic.Int32
+	server := newCountingServer(50*time.Millisecond, &requests)
+	defer server.Close()
+
+	client := collector.New(server.URL, collector.WithModules("job_queue")).Client()
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var wg sync.WaitGroup
+	errs := make([]error, 20)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := context.Background()
+			if i%2 == 1 {
+				ctx = canceled
+			}
+			_, errs[i] = client.JobQueueStatus(ctx)
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if i%2 == 0 && err != nil {
+			t.Errorf("Expected request %d with a live context to succeed, got %v", i, err)
+		}
+		if i%2 == 1 && err == nil {
+			t.Errorf("Expected request %d with a canceled context to fail", i)
+		}
+	}
+}
+
+func TestResponseCacheTTL(t *testing.T) {
+	tests := []struct {
+		name     string
+		ttl      time.Duration
+		requests int32
+	}{
+		{name: "caching disabled", ttl: 0, requests: 2},
+		{name: "cached for ttl", ttl: time.Minute, requests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := newCountingServer(0, &requests)
+			defer server.Close()
+
+			for i := 0; i < 2; i++ {
+				c := newCollector(context.Background(), server.URL, []string{"job_queue"}, collector.ClientConfig{CacheTTL: tt.ttl})
+				if success := collectModuleSuccess(t, c); success["job_queue"] != 1 {
+					t.Fatalf("Expected job_queue success, got %v", success["job_queue"])
+				}
+			}
+			if n := requests.Load(); n != tt.requests {
+				t.Errorf("Expected %d requests, got %d", tt.requests, n)
+			}
+		})
+	}
+}
+
+// Test that a cached response is only used while it is younger than the
+// cache TTL of the probe reading it, not the TTL of the probe that stored it
+func TestResponseCacheReaderTTL(t *testing.T) {
+	var requests atomic.Int32
+	server := newCountingServer(0, &requests)
+	defer server.Close()
+
+	probe := func(ttl time.Duration) {
+		c := newCollector(context.Background(), server.URL, []string{"job_queue"}, collector.ClientConfig{CacheTTL: ttl})
+		if success := collectModuleSuccess(t, c); success["job_queue"] != 1 {
+			t.Fatalf("Expected job_queue success, got %v", success["job_queue"])
+		}
+	}
+
+	probe(time.Hour)
+	time.Sleep(100 * time.Millisecond)
+	probe(50 * time.Millisecond)
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Expected the probe with the short TTL to request again, got %d requests", n)
+	}
+	probe(time.Hour)
+	if n := requests.Load(); n != 2 {
+		t.Errorf("Expected the probe with the long TTL to use the cached response, got %d requests", n)
+	}
+	probe(0)
+	if n := requests.Load(); n != 3 {
+		t.Errorf("Expected the probe without caching to request again, got %d requests", n)
+	}
+}
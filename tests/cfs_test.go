@@ -30,7 +30,7 @@ func TestCFSMetrics(t *testing.T) {
 	defer server.Close()
 
 	// Create collector instance using New function
-	c := collector.New(context.Background(), server.URL[7:], []string{"cfs"}, "")
+	c := newCollector(context.Background(), server.URL[7:], []string{"cfs"}, collector.ClientConfig{})
 
 	// Test collectCFS function by checking generated metrics
 	ch := make(chan prometheus.Metric, 100)
@@ -0,0 +1,160 @@
+package test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// exporterBinary is the exporter built by TestMain, for testing the commands
+// and handlers of the main package
+var exporterBinary string
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "klipper-exporter-test")
+	if err != nil {
+		panic(err)
+	}
+	exporterBinary = filepath.Join(dir, "prometheus-klipper-exporter")
+	build := exec.Command("go", "build", "-o", exporterBinary, "..")
+	build.Stdout, build.Stderr = os.Stderr, os.Stderr
+	if err := build.Run(); err != nil {
+		os.RemoveAll(dir)
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// runExporter runs the exporter with the arguments and returns the exit code,
+// stdout, and stderr
+func runExporter(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(exporterBinary, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		t.Fatalf("Failed to run the exporter: %v", err)
+	}
+	return cmd.ProcessState.ExitCode(), stdout.String(), stderr.String()
+}
+
+// newJobQueueServer serves the job queue, and fails the other requests
+func newJobQueueServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/server/job_queue/status" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(jobQueueFixture))
+	}))
+}
+
+func TestProbeCommandExitCodes(t *testing.T) {
+	server := newJobQueueServer()
+	defer server.Close()
+	target := strings.TrimPrefix(server.URL, "http://")
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{name: "success", args: []string{"--target", target, "--modules", "job_queue"}, code: 0},
+		{name: "module failed", args: []string{"--target", target, "--modules", "job_queue,history"}, code: 1},
+		{name: "no modules", args: []string{"--target", target, "--modules", "unknown"}, code: 1},
+		{name: "target down", args: []string{"--target", down.URL, "--modules", "job_queue"}, code: 1},
+		{name: "missing target", args: []string{"--modules", "job_queue"}, code: 2},
+		{name: "invalid format", args: []string{"--target", target, "--format", "yaml"}, code: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _, stderr := runExporter(t, append([]string{"probe"}, tt.args...)...); code != tt.code {
+				t.Errorf("Expected exit code %d, got %d: %s", tt.code, code, stderr)
+			}
+		})
+	}
+}
+
+// Test that the probe command prints the metrics, and the outcome and duration
+// of each module
+func TestProbeCommandText(t *testing.T) {
+	server := newJobQueueServer()
+	defer server.Close()
+
+	code, stdout, stderr := runExporter(t, "probe", "--target", server.URL, "--modules", "job_queue,history")
+	if code != 1 {
+		t.Errorf("Expected exit code 1, got %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, "klipper_job_queue_length 0") || !strings.Contains(stdout, "klipper_up 1") {
+		t.Errorf("Expected the job queue metrics, got %s", stdout)
+	}
+	if !strings.Contains(stderr, "Probe of "+server.URL+" ("+server.URL+") took ") {
+		t.Errorf("Expected the probe duration, got %s", stderr)
+	}
+	for module, result := range map[string]string{"job_queue": "OK", "history": "FAILED: "} {
+		timing := regexp.MustCompile(`(?m)^  ` + module + ` +\d+\.\d{3}s  ` + result)
+		if !timing.MatchString(stderr) {
+			t.Errorf("Expected the %s duration and %s, got %s", module, result, stderr)
+		}
+	}
+}
+
+func TestProbeCommandJSON(t *testing.T) {
+	server := newJobQueueServer()
+	defer server.Close()
+
+	code, stdout, stderr := runExporter(t, "probe", "--target", server.URL, "--modules", "job_queue", "--format", "json")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr)
+	}
+	var output struct {
+		Target  string `json:"target"`
+		Up      bool   `json:"up"`
+		Modules []struct {
+			Module   string   `json:"module"`
+			Success  bool     `json:"success"`
+			Duration *float64 `json:"duration_seconds"`
+		} `json:"modules"`
+		Metrics []struct {
+			Name    string `json:"name"`
+			Type    string `json:"type"`
+			Samples []struct {
+				Labels map[string]string `json:"labels"`
+				Value  any               `json:"value"`
+			} `json:"samples"`
+		} `json:"metrics"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &output); err != nil {
+		t.Fatalf("Failed to decode the JSON output: %v\n%s", err, stdout)
+	}
+
+	if output.Target != server.URL || !output.Up {
+		t.Errorf("Expected target %s up, got %s up %v", server.URL, output.Target, output.Up)
+	}
+	if len(output.Modules) != 1 || output.Modules[0].Module != "job_queue" || !output.Modules[0].Success || output.Modules[0].Duration == nil {
+		t.Errorf("Expected the job_queue outcome and duration, got %+v", output.Modules)
+	}
+	found := false
+	for _, metric := range output.Metrics {
+		if metric.Name == "klipper_job_queue_length" {
+			found = metric.Type == "gauge" && len(metric.Samples) == 1 && metric.Samples[0].Value == 0.0
+		}
+	}
+	if !found {
+		t.Errorf("Expected the klipper_job_queue_length gauge, got %+v", output.Metrics)
+	}
+}
@@ -0,0 +1,60 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+// Test that modules are collected in parallel rather than one after another
+func TestCollectModulesConcurrently(t *testing.T) {
+	const delay = 300 * time.Millisecond
+
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(delay)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"result": {}}`))
+	}))
+	defer server.Close()
+
+	modules := []string{"job_queue", "server_info", "system_info", "directory_info"}
+	c := newCollector(context.Background(), server.URL[7:], modules, collector.ClientConfig{})
+
+	ch := make(chan prometheus.Metric, 100)
+	start := time.Now()
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	count := 0
+	for range ch {
+		count++
+	}
+	elapsed := time.Since(start)
+
+	if count == 0 {
+		t.Error("No metrics were collected")
+	}
+	if elapsed >= time.Duration(len(modules))*delay {
+		t.Errorf("Collect took %s, expected modules to be collected concurrently", elapsed)
+	}
+	if maxInFlight.Load() < 2 {
+		t.Errorf("Expected concurrent requests to Moonraker, got at most %d in flight", maxInFlight.Load())
+	}
+}
@@ -0,0 +1,255 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/scross01/prometheus-klipper-exporter/config"
+)
+
+// writeConfig writes the config file contents to a temporary file
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "klipper-exporter.yml")
+	if err := os.WriteFile(filename, []byte(contents), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return filename
+}
+
+func TestLoadConfig(t *testing.T) {
+	filename := writeConfig(t, `
+targets:
+  voron24:
+    address: 192.168.1.10:7125
+    api_key: abcdef01234567890123456789012345
+    modules: [ printer_objects, mmu ]
+    labels:
+      printer: Voron 2.4
+      room: garage
+  ender3:
+    address: https://farm.lan/printer3/
+    username: exporter
+    password: s3cret
+    headers:
+      X-Farm-Token: abc123
+    tls:
+      ca_file: /etc/ssl/farm-ca.pem
+      insecure_skip_verify: false
+    subscribe: true
+    cache_ttl: 5s
+`)
+
+	cfg, err := config.Load(filename)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	voron, ok := cfg.Target("voron24")
+	if !ok {
+		t.Fatal("Expected voron24 target")
+	}
+	if voron.Address != "192.168.1.10:7125" || voron.APIKey != "abcdef01234567890123456789012345" {
+		t.Errorf("Unexpected voron24 target: %+v", voron)
+	}
+	if len(voron.Modules) != 2 || voron.Labels["printer"] != "Voron 2.4" || voron.Labels["room"] != "garage" {
+		t.Errorf("Unexpected voron24 modules or labels: %+v", voron)
+	}
+
+	ender, ok := cfg.Target("ender3")
+	if !ok {
+		t.Fatal("Expected ender3 target")
+	}
+	if ender.Username != "exporter" || ender.Password != "s3cret" || ender.Headers["X-Farm-Token"] != "abc123" {
+		t.Errorf("Unexpected ender3 credentials or headers: %+v", ender)
+	}
+	if ender.TLS.CAFile != "/etc/ssl/farm-ca.pem" || ender.TLS.InsecureSkipVerify == nil || *ender.TLS.InsecureSkipVerify {
+		t.Errorf("Unexpected ender3 TLS settings: %+v", ender.TLS)
+	}
+	if ender.Subscribe == nil || !*ender.Subscribe || ender.CacheTTL == nil || *ender.CacheTTL != 5*time.Second {
+		t.Errorf("Unexpected ender3 transport settings: %+v", ender)
+	}
+
+	if _, ok := cfg.Target("klipper-host:7125"); ok {
+		t.Error("Expected unknown target not to resolve")
+	}
+}
+
+func TestLoadInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		err      string
+	}{
+		{
+			name:     "missing address",
+			contents: "targets:\n  voron24:\n    api_key: abc\n",
+			err:      "address must be set",
+		},
+		{
+			name:     "api key and login",
+			contents: "targets:\n  voron24:\n    address: host:7125\n    api_key: abc\n    username: exporter\n",
+			err:      "only one of api_key and username",
+		},
+		{
+			name:     "invalid label name",
+			contents: "targets:\n  voron24:\n    address: host:7125\n    labels:\n      printer-name: Voron\n",
+			err:      "invalid label name",
+		},
+		{
+			name:     "invalid metric filter",
+			contents: "targets:\n  voron24:\n    address: host:7125\n    exclude: [ \"klipper_(\" ]\n",
+			err:      "invalid metric filter pattern",
+		},
+		{
+			name:     "unknown profile",
+			contents: "targets:\n  voron24:\n    address: host:7125\n    profile: farm\n",
+			err:      "unknown profile farm",
+		},
+		{
+			name:     "empty profile",
+			contents: "profiles:\n  farm: []\n",
+			err:      "profile farm: modules must be set",
+		},
+		{
+			name:     "unknown field",
+			contents: "targets:\n  voron24:\n    address: host:7125\n    apikey: abc\n",
+			err:      "field apikey not found",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.Load(writeConfig(t, tt.contents))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestProfileModules(t *testing.T) {
+	filename := writeConfig(t, `
+profiles:
+  default: [ server_info, job_queue ]
+  farm: [ auto, -query_endstops ]
+  mmu-printer: [ printer_objects, mmu ]
+targets:
+  voron24:
+    address: 192.168.1.10:7125
+    profile: mmu-printer
+`)
+	cfg, err := config.Load(filename)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if voron, _ := cfg.Target("voron24"); voron.Profile != "mmu-printer" {
+		t.Errorf("Expected voron24 profile mmu-printer, got %q", voron.Profile)
+	}
+
+	tests := []struct {
+		name     string
+		profile  string
+		modules  []string
+		expected []string
+	}{
+		{name: "default profile", expected: []string{"server_info", "job_queue"}},
+		{name: "default profile exclusion", modules: []string{"-job_queue"}, expected: []string{"server_info", "job_queue", "-job_queue"}},
+		{name: "modules replace default profile", modules: []string{"history"}, expected: []string{"history"}},
+		{name: "profile", profile: "farm", expected: []string{"auto", "-query_endstops"}},
+		{name: "profile additions", profile: "mmu-printer", modules: []string{"history", "-mmu"}, expected: []string{"printer_objects", "mmu", "history", "-mmu"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modules, err := cfg.Modules(tt.profile, tt.modules)
+			if err != nil {
+				t.Fatalf("Failed to get modules: %v", err)
+			}
+			if strings.Join(modules, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("Expected modules %v, got %v", tt.expected, modules)
+			}
+		})
+	}
+
+	if _, err := cfg.Modules("full", nil); err == nil || !strings.Contains(err.Error(), "unknown profile full") {
+		t.Errorf("Expected unknown profile error, got %v", err)
+	}
+	var empty *config.Config
+	if modules, err := empty.Modules("", []string{"-job_queue"}); err != nil || len(modules) != 1 {
+		t.Errorf("Expected the modules unchanged without a config file, got %v, %v", modules, err)
+	}
+}
+
+func TestSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	apiKeyFile := filepath.Join(dir, "apikey")
+	if err := os.WriteFile(apiKeyFile, []byte("abcdef01234567890123456789012345\n"), 0600); err != nil {
+		t.Fatalf("Failed to write API key file: %v", err)
+	}
+
+	filename := writeConfig(t, "targets:\n  voron24:\n    address: 192.168.1.10:7125\n    api_key_file: "+apiKeyFile+"\n")
+	cfg, err := config.Load(filename)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	target, _ := cfg.Target("voron24")
+	secrets := &config.Secrets{}
+
+	apiKey, _, err := target.Credentials(secrets)
+	if err != nil || apiKey != "abcdef01234567890123456789012345" {
+		t.Fatalf("Expected API key from file, got %q: %v", apiKey, err)
+	}
+
+	// a rotated secret is re-read without reloading the config
+	if err := os.WriteFile(apiKeyFile, []byte("98765432109876543210987654321098\n"), 0600); err != nil {
+		t.Fatalf("Failed to write API key file: %v", err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(apiKeyFile, future, future); err != nil {
+		t.Fatalf("Failed to update API key file time: %v", err)
+	}
+	apiKey, _, err = target.Credentials(secrets)
+	if err != nil || apiKey != "98765432109876543210987654321098" {
+		t.Errorf("Expected rotated API key from file, got %q: %v", apiKey, err)
+	}
+
+	// a missing secret file is reported when the credentials are used
+	os.Remove(apiKeyFile)
+	if _, _, err := target.Credentials(secrets); err == nil {
+		t.Error("Expected error for missing API key file")
+	}
+}
+
+func TestLoadInvalidSecretConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		err      string
+	}{
+		{
+			name:     "api key and api key file",
+			contents: "targets:\n  voron24:\n    address: host:7125\n    api_key: abc\n    api_key_file: /run/secrets/apikey\n",
+			err:      "only one of api_key and api_key_file",
+		},
+		{
+			name:     "password file without username",
+			contents: "targets:\n  voron24:\n    address: host:7125\n    password_file: /run/secrets/password\n",
+			err:      "password requires username",
+		},
+		{
+			name:     "password and password file",
+			contents: "targets:\n  voron24:\n    address: host:7125\n    username: exporter\n    password: abc\n    password_file: /run/secrets/password\n",
+			err:      "only one of password and password_file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := config.Load(writeConfig(t, tt.contents))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+// newDetectServer serves the Moonraker components and printer objects used for
+// module detection, with Klippy in the state returned by klippyState
+func newDetectServer(components string, klippyState func() string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/server/info":
+			w.Write([]byte(`{"result": {"klippy_connected": true, "klippy_state": "` + klippyState() + `", "components": ` + components + `}}`))
+		case "/printer/objects/list":
+			w.Write([]byte(`{"result": {"objects": ["toolhead", "extruder", "mmu"]}}`))
+		default:
+			w.Write([]byte(`{"result": {}}`))
+		}
+	}))
+}
+
+// collectModulesEnabled runs a collection and returns the
+// klipper_exporter_module_enabled values
+func collectModulesEnabled(t *testing.T, c *collector.Collector) map[string]float64 {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 500)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	enabled := make(map[string]float64)
+	for m := range ch {
+		if metricName(m) == "klipper_exporter_module_enabled" {
+			enabled[metricLabel(t, m, "module")] = metricValue(t, m)
+		}
+	}
+	return enabled
+}
+
+func TestAutoModules(t *testing.T) {
+	server := newDetectServer(`["history", "spoolman", "job_queue"]`, func() string { return "ready" })
+	defer server.Close()
+
+	c := collector.New(server.URL, collector.WithModules("auto", "network_stats"))
+	enabled := collectModulesEnabled(t, c)
+	expected := map[string]float64{
+		"history":         1,
+		"spoolman":        1,
+		"job_queue":       1,
+		"mmu":             1,
+		"printer_objects": 1,
+		"query_endstops":  1,
+		"cfs":             0,
+		"device_power":    0,
+		"directory_info":  0,
+		// default modules without detection
+		"server_info":   1,
+		"process_stats": 1,
+		"system_info":   1,
+		// enabled explicitly
+		"network_stats": 1,
+	}
+	for module, value := range expected {
+		if enabled[module] != value {
+			t.Errorf("Expected klipper_exporter_module_enabled{module=%q} %v, got %v", module, value, enabled[module])
+		}
+	}
+}
+
+// Test that modules excluded with -module are not collected, including modules
+// enabled by auto and the default modules
+func TestExcludeModules(t *testing.T) {
+	server := newDetectServer(`["history", "job_queue"]`, func() string { return "ready" })
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		modules  []string
+		enabled  []string
+		disabled []string
+	}{
+		{name: "default modules", modules: []string{"-query_endstops"}, enabled: []string{"server_info", "job_queue"}, disabled: []string{"query_endstops", "history"}},
+		{name: "auto", modules: []string{"auto", "-history"}, enabled: []string{"job_queue", "printer_objects"}, disabled: []string{"history"}},
+		{name: "added and excluded", modules: []string{"history", "-history"}, disabled: []string{"history"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enabled := collectModulesEnabled(t, collector.New(server.URL, collector.WithModules(tt.modules...)))
+			for _, module := range tt.enabled {
+				if enabled[module] != 1 {
+					t.Errorf("Expected %s to be enabled, got %v", module, enabled)
+				}
+			}
+			for _, module := range tt.disabled {
+				if enabled[module] != 0 {
+					t.Errorf("Expected %s to be excluded, got %v", module, enabled)
+				}
+			}
+		})
+	}
+}
+
+// Test that detected modules are cached, unless Klippy wasn't ready
+func TestAutoModulesCached(t *testing.T) {
+	var ready atomic.Bool
+	server := newDetectServer(`["history"]`, func() string {
+		if ready.Load() {
+			return "ready"
+		}
+		return "startup"
+	})
+	defer server.Close()
+
+	state := collector.NewState()
+	probe := func() map[string]float64 {
+		return collectModulesEnabled(t, collector.New(server.URL, collector.WithModules("auto"), collector.WithState(state)))
+	}
+
+	if enabled := probe(); enabled["printer_objects"] != 0 || enabled["query_endstops"] != 0 || enabled["history"] != 1 {
+		t.Errorf("Expected history but not printer_objects or query_endstops while Klippy is starting, got %v", enabled)
+	}
+	ready.Store(true)
+	if enabled := probe(); enabled["printer_objects"] != 1 || enabled["mmu"] != 1 {
+		t.Errorf("Expected printer_objects and mmu once Klippy is ready, got %v", enabled)
+	}
+	ready.Store(false)
+	if enabled := probe(); enabled["printer_objects"] != 1 || enabled["mmu"] != 1 {
+		t.Errorf("Expected the cached printer_objects and mmu, got %v", enabled)
+	}
+}
+
+// Test that Describe returns the metrics of every module auto can enable
+func TestAutoModulesDescribe(t *testing.T) {
+	c := collector.New("localhost:7125", collector.WithModules("auto"))
+	ch := make(chan *prometheus.Desc, 500)
+	c.Describe(ch)
+	close(ch)
+
+	described := map[string]bool{}
+	for desc := range ch {
+		name, _, _ := strings.Cut(strings.SplitN(desc.String(), `fqName: "`, 2)[1], `"`)
+		described[name] = true
+	}
+	for _, m := range collector.Metrics() {
+		switch m.Module {
+		case "mmu", "cfs", "spoolman", "history", "printer_objects", "server_info":
+			if !described[m.Name] {
+				t.Errorf("Expected descriptor for %s of the %s module", m.Name, m.Module)
+			}
+		case "network_stats", "directory_info":
+			if described[m.Name] {
+				t.Errorf("Expected no descriptor for %s of the %s module", m.Name, m.Module)
+			}
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+// Test that the printer objects with all their metrics excluded are not queried
+func TestMetricFilterExcludesPrinterObjects(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/printer/objects/list":
+			w.Write([]byte(`{"result": {"objects": ["mcu", "mcu rpi", "extruder", "input_shaper", "temperature_sensor chamber"]}}`))
+		case "/printer/objects/query":
+			query, _ = url.QueryUnescape(r.URL.RawQuery)
+			w.Write([]byte(`{"result": {"status": {"extruder": {"temperature": 210.0}, "temperature_sensor chamber": {"temperature": 35.0}}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	filter, err := collector.ParseMetricFilter(nil, []string{"klipper_mcu_.*", "klipper_input_shaper_.*"}, false)
+	if err != nil {
+		t.Fatalf("Failed to parse metric filter: %v", err)
+	}
+	values := collectGauges(t, collector.New(server.URL, collector.WithModules("printer_objects"), collector.WithMetricFilter(filter)))
+
+	for _, object := range []string{"mcu", "input_shaper"} {
+		if strings.Contains(query, object) {
+			t.Errorf("Expected no %s object in the query, got %s", object, query)
+		}
+	}
+	if !strings.Contains(query, "extruder") || !strings.Contains(query, "temperature_sensor chamber") {
+		t.Errorf("Expected the extruder and chamber objects in the query, got %s", query)
+	}
+	for name := range values {
+		if strings.HasPrefix(name, "klipper_mcu_") || strings.HasPrefix(name, "klipper_input_shaper_") {
+			t.Errorf("Expected excluded metric %s not to be collected", name)
+		}
+	}
+	if values["klipper_extruder_temperature"] != 210 || values["klipper_up"] != 1 {
+		t.Errorf("Expected klipper_extruder_temperature 210 and klipper_up 1, got %v", values)
+	}
+}
+
+// Test that modules with all their metrics excluded are not collected
+func TestMetricFilterIncludeSkipsModules(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		requested []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested = append(requested, r.URL.Path)
+		mu.Unlock()
+		w.Write([]byte(jobQueueFixture))
+	}))
+	defer server.Close()
+
+	filter, err := collector.ParseMetricFilter([]string{"klipper_job_queue_.*"}, nil, false)
+	if err != nil {
+		t.Fatalf("Failed to parse metric filter: %v", err)
+	}
+	c := collector.New(server.URL, collector.WithModules("job_queue", "process_stats", "system_info"), collector.WithMetricFilter(filter))
+	values := collectGauges(t, c)
+
+	if len(requested) != 1 || requested[0] != "/server/job_queue/status" {
+		t.Errorf("Expected only the job queue to be requested, got %v", requested)
+	}
+	for name := range values {
+		if !strings.HasPrefix(name, "klipper_job_queue_") && !strings.HasPrefix(name, "klipper_exporter_") && name != "klipper_up" {
+			t.Errorf("Expected metric %s not to be collected", name)
+		}
+	}
+	if _, ok := values["klipper_job_queue_length"]; !ok {
+		t.Errorf("Expected klipper_job_queue_length, got %v", values)
+	}
+}
+
+func TestMetricFilterLabelValues(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/machine/device_power/devices":
+			w.Write([]byte(`{"result": {"devices": [{"device": "printer", "type": "gpio"}, {"device": "light-strip", "type": "gpio"}]}}`))
+		default:
+			w.Write([]byte(`{"result": {"printer": "on", "light-strip": "off"}}`))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		include  []string
+		exclude  []string
+		expected string
+	}{
+		{name: "exclude", exclude: []string{"light_strip"}, expected: "printer"},
+		{name: "include", include: []string{"light_.*"}, expected: "light_strip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := collector.ParseMetricFilter(tt.include, tt.exclude, true)
+			if err != nil {
+				t.Fatalf("Failed to parse metric filter: %v", err)
+			}
+			c := collector.New(server.URL, collector.WithModules("device_power"), collector.WithMetricFilter(filter))
+			ch := make(chan prometheus.Metric, 100)
+			go func() {
+				c.Collect(ch)
+				close(ch)
+			}()
+			devices := map[string]bool{}
+			up := false
+			for m := range ch {
+				if device := metricLabel(t, m, "device"); device != "" {
+					devices[device] = true
+				}
+				up = up || metricName(m) == "klipper_up"
+			}
+			if len(devices) != 1 || !devices[tt.expected] {
+				t.Errorf("Expected only series of device %s, got %v", tt.expected, devices)
+			}
+			if !up {
+				t.Error("Expected klipper_up to always be collected")
+			}
+		})
+	}
+}
+
+func TestParseMetricFilterInvalid(t *testing.T) {
+	if _, err := collector.ParseMetricFilter([]string{"klipper_("}, nil, false); err == nil || !strings.Contains(err.Error(), "invalid include pattern") {
+		t.Errorf("Expected invalid include pattern error, got %v", err)
+	}
+	if _, err := collector.ParseMetricFilter(nil, []string{"a", "(b"}, false); err == nil || !strings.Contains(err.Error(), "invalid exclude pattern") {
+		t.Errorf("Expected invalid exclude pattern error, got %v", err)
+	}
+}
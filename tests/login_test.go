@@ -0,0 +1,137 @@
+package test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+// fakeJWT returns an unsigned token with the given expiry claim
+func fakeJWT(id int, exp time.Time) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"jti":"%d","exp":%d}`, id, exp.Unix())))
+	return header + "." + payload + ".sig"
+}
+
+// loginServer simulates Moonraker with user accounts enabled
+type loginServer struct {
+	mu       sync.Mutex
+	lifetime time.Duration
+	issued   int
+	valid    map[string]bool
+	logins   int
+	refresh  int
+}
+
+func (s *loginServer) issue() string {
+	s.issued++
+	token := fakeJWT(s.issued, time.Now().Add(s.lifetime))
+	s.valid[token] = true
+	return token
+}
+
+func (s *loginServer) revokeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.valid = map[string]bool{}
+}
+
+func (s *loginServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.URL.Path {
+	case "/access/login":
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["username"] != "exporter" || body["password"] != "s3cret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		s.logins++
+		fmt.Fprintf(w, `{"result": {"username": "exporter", "token": %q, "refresh_token": "refresh", "action": "user_logged_in", "source": %q}}`, s.issue(), body["source"])
+	case "/access/refresh_jwt":
+		s.refresh++
+		fmt.Fprintf(w, `{"result": {"username": "exporter", "token": %q, "source": "moonraker", "action": "user_jwt_refresh"}}`, s.issue())
+	case "/server/job_queue/status":
+		if len(r.Header.Get("Authorization")) < 7 || !s.valid[r.Header.Get("Authorization")[7:]] {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(jobQueueFixture))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestLoginAuthentication(t *testing.T) {
+	t.Run("login once and reuse the token", func(t *testing.T) {
+		moonraker := &loginServer{lifetime: time.Hour, valid: map[string]bool{}}
+		server := httptest.NewServer(moonraker)
+		defer server.Close()
+
+		config := collector.ClientConfig{Login: &collector.Login{Username: "exporter", Password: "s3cret"}}
+		for i := 0; i < 2; i++ {
+			c := newCollector(context.Background(), server.URL, []string{"job_queue"}, config)
+			if success := collectModuleSuccess(t, c); success["job_queue"] != 1 {
+				t.Fatalf("Expected job_queue success on probe %d, got %v", i, success["job_queue"])
+			}
+		}
+		if moonraker.logins != 1 {
+			t.Errorf("Expected 1 login, got %d", moonraker.logins)
+		}
+	})
+
+	t.Run("login again after token is rejected", func(t *testing.T) {
+		moonraker := &loginServer{lifetime: time.Hour, valid: map[string]bool{}}
+		server := httptest.NewServer(moonraker)
+		defer server.Close()
+
+		config := collector.ClientConfig{Login: &collector.Login{Username: "exporter", Password: "s3cret"}}
+		collectModuleSuccess(t, newCollector(context.Background(), server.URL, []string{"job_queue"}, config))
+		moonraker.revokeAll()
+		c := newCollector(context.Background(), server.URL, []string{"job_queue"}, config)
+		if success := collectModuleSuccess(t, c); success["job_queue"] != 1 {
+			t.Fatalf("Expected job_queue success after re-login, got %v", success["job_queue"])
+		}
+		if moonraker.logins != 2 {
+			t.Errorf("Expected 2 logins, got %d", moonraker.logins)
+		}
+	})
+
+	t.Run("refresh token before expiry", func(t *testing.T) {
+		moonraker := &loginServer{lifetime: time.Minute, valid: map[string]bool{}}
+		server := httptest.NewServer(moonraker)
+		defer server.Close()
+
+		config := collector.ClientConfig{Login: &collector.Login{Username: "exporter", Password: "s3cret"}}
+		for i := 0; i < 2; i++ {
+			c := newCollector(context.Background(), server.URL, []string{"job_queue"}, config)
+			if success := collectModuleSuccess(t, c); success["job_queue"] != 1 {
+				t.Fatalf("Expected job_queue success on probe %d, got %v", i, success["job_queue"])
+			}
+		}
+		if moonraker.logins != 1 || moonraker.refresh != 1 {
+			t.Errorf("Expected 1 login and 1 refresh, got %d logins and %d refreshes", moonraker.logins, moonraker.refresh)
+		}
+	})
+
+	t.Run("invalid credentials", func(t *testing.T) {
+		moonraker := &loginServer{lifetime: time.Hour, valid: map[string]bool{}}
+		server := httptest.NewServer(moonraker)
+		defer server.Close()
+
+		config := collector.ClientConfig{Login: &collector.Login{Username: "exporter", Password: "wrong"}}
+		c := newCollector(context.Background(), server.URL, []string{"job_queue"}, config)
+		if success := collectModuleSuccess(t, c); success["job_queue"] != 0 {
+			t.Errorf("Expected job_queue failure with invalid credentials, got %v", success["job_queue"])
+		}
+	})
+}
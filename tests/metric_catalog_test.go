@@ -0,0 +1,174 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+// Test that Describe returns the metrics of the enabled modules and the probe
+// outcome metrics
+func TestDescribe(t *testing.T) {
+	c := newCollector(context.Background(), "localhost:7125", []string{"job_queue"}, collector.ClientConfig{})
+	ch := make(chan *prometheus.Desc, 300)
+	c.Describe(ch)
+	close(ch)
+
+	var described []string
+	for desc := range ch {
+		described = append(described, desc.String())
+	}
+	expected := []string{
+		"klipper_job_queue_length",
+		"klipper_job_queue_state_info",
+		"klipper_exporter_module_success",
+		"klipper_exporter_module_duration_seconds",
+		"klipper_up",
+		"klipper_exporter_module_enabled",
+	}
+	if len(described) != len(expected) {
+		t.Fatalf("Expected %d descriptors, got %d: %v", len(expected), len(described), described)
+	}
+	for _, name := range expected {
+		found := false
+		for _, desc := range described {
+			found = found || strings.Contains(desc, `fqName: "`+name+`"`)
+		}
+		if !found {
+			t.Errorf("Expected descriptor for %s", name)
+		}
+	}
+}
+
+// Test that every collected metric is described, by gathering from a pedantic
+// registry which rejects undescribed and inconsistent metrics
+func TestCollectedMetricsAreDescribed(t *testing.T) {
+	for _, module := range []string{"mmu", "cfs"} {
+		t.Run(module, func(t *testing.T) {
+			responseData, err := os.ReadFile(module + "_response.json")
+			if err != nil {
+				t.Fatalf("Failed to read test response: %v", err)
+			}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write(responseData)
+			}))
+			defer server.Close()
+
+			registry := prometheus.NewPedanticRegistry()
+			registry.MustRegister(newCollector(context.Background(), server.URL[7:], []string{module}, collector.ClientConfig{}))
+			families, err := registry.Gather()
+			if err != nil {
+				t.Fatalf("Failed to gather metrics: %v", err)
+			}
+			if len(families) <= 3 {
+				t.Errorf("Expected %s metrics, got %d metric families", module, len(families))
+			}
+			if dropped := gatheredValue(t, "klipper_exporter_emit_errors_total", "module", module); dropped != 0 {
+				t.Errorf("Expected no dropped %s series, got %v", module, dropped)
+			}
+		})
+	}
+}
+
+// Test that a series with a label value that is not valid UTF-8 is dropped and
+// counted, and the rest of the probe succeeds. Moonraker responses are decoded
+// to valid UTF-8, so the invalid value comes from a label sanitizer that
+// truncates the name of the power device in the middle of a character.
+func TestInvalidUTF8LabelValueDropped(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/machine/device_power/devices":
+			w.Write([]byte(`{"result": {"devices": [{"device": "printer", "type": "gpio"}, {"device": "türkis", "type": "gpio"}]}}`))
+		default:
+			w.Write([]byte(`{"result": {"printer": "on", "türkis": "off"}}`))
+		}
+	}))
+	defer server.Close()
+
+	metrics := []string{"klipper_power_device_info", "klipper_power_device_status", "klipper_power_device_state_info"}
+	dropped := map[string]float64{}
+	for _, metric := range metrics {
+		dropped[metric] = gatheredValue(t, "klipper_exporter_emit_errors_total", "metric", metric)
+	}
+
+	truncate := func(name string) string { return name[:min(len(name), 2)] }
+	c := collector.New(server.URL,
+		collector.WithModules("device_power"),
+		collector.WithLabelSanitizer(truncate),
+		collector.WithState(testState),
+	)
+	ch := make(chan prometheus.Metric, 100)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	devices := map[string]int{}
+	values := map[string]float64{}
+	for m := range ch {
+		name := metricName(m)
+		if strings.HasPrefix(name, "klipper_power_device_") {
+			devices[metricLabel(t, m, "device")]++
+		}
+		if name == "klipper_up" || (name == "klipper_exporter_module_success" && metricLabel(t, m, "module") == "device_power") {
+			values[name] = metricValue(t, m)
+		}
+	}
+
+	if len(devices) != 1 || devices["pr"] != len(metrics) {
+		t.Errorf("Expected only the %d series of the printer device, got %v", len(metrics), devices)
+	}
+	if values["klipper_up"] != 1 || values["klipper_exporter_module_success"] != 1 {
+		t.Errorf("Expected klipper_up and device_power success 1, got %v", values)
+	}
+	for _, metric := range metrics {
+		if d := gatheredValue(t, "klipper_exporter_emit_errors_total", "metric", metric) - dropped[metric]; d != 1 {
+			t.Errorf("Expected 1 dropped %s series, got %v", metric, d)
+		}
+	}
+}
+
+// docsMetricRow matches a metric in the metrics tables of docs/metrics, e.g.
+// | `klipper_up` | Gauge | ...
+var docsMetricRow = regexp.MustCompile("^\\| `(klipper_[a-z0-9_]+)` \\| ([A-Za-z]+)")
+
+// Test that every metric in the catalog is documented in docs/metrics with the
+// same type
+func TestMetricCatalogDocumented(t *testing.T) {
+	files, err := filepath.Glob("../docs/metrics/*.md")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("Failed to find metrics docs: %v", err)
+	}
+	documented := make(map[string]string)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", file, err)
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			if m := docsMetricRow.FindStringSubmatch(line); m != nil {
+				documented[m[1]] = strings.ToLower(m[2])
+			}
+		}
+	}
+
+	catalog := collector.Metrics()
+	if len(catalog) == 0 {
+		t.Fatal("Expected metrics in the catalog")
+	}
+	for _, m := range catalog {
+		docType, ok := documented[m.Name]
+		if !ok {
+			t.Errorf("Metric %s of module %s is not documented", m.Name, m.Module)
+		} else if docType != m.Type {
+			t.Errorf("Metric %s is documented as %s, expected %s", m.Name, docType, m.Type)
+		}
+	}
+}
@@ -28,7 +28,7 @@ func TestMMUMetrics(t *testing.T) {
 	defer server.Close()
 
 	// Create collector instance using New function
-	c := collector.New(context.Background(), server.URL[7:], []string{"mmu"}, "")
+	c := newCollector(context.Background(), server.URL[7:], []string{"mmu"}, collector.ClientConfig{})
 
 	// Test collectMMU function by checking generated metrics
 	ch := make(chan prometheus.Metric, 100)
@@ -101,7 +101,7 @@ func TestMMUPreGateSensors(t *testing.T) {
 	defer sensorServer.Close()
 
 	// Create collector instance
-	c := collector.New(context.Background(), sensorServer.URL[7:], []string{"mmu"}, "")
+	c := newCollector(context.Background(), sensorServer.URL[7:], []string{"mmu"}, collector.ClientConfig{})
 
 	// Test by calling Collect which should include MMU sensor data collection
 	ch := make(chan prometheus.Metric, 100)
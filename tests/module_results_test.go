@@ -0,0 +1,97 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+// metricName extracts the fully qualified metric name from a metric descriptor
+func metricName(m prometheus.Metric) string {
+	desc := m.Desc().String()
+	start := strings.Index(desc, `fqName: "`) + len(`fqName: "`)
+	end := strings.Index(desc[start:], `"`)
+	return desc[start : start+end]
+}
+
+// metricValue returns the gauge or counter value of a metric
+func metricValue(t *testing.T, m prometheus.Metric) float64 {
+	t.Helper()
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		t.Fatalf("Failed to write metric: %v", err)
+	}
+	if pb.Gauge != nil {
+		return pb.Gauge.GetValue()
+	}
+	return pb.Counter.GetValue()
+}
+
+// metricLabel returns the value of the named label of a metric
+func metricLabel(t *testing.T, m prometheus.Metric, name string) string {
+	t.Helper()
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		t.Fatalf("Failed to write metric: %v", err)
+	}
+	for _, label := range pb.GetLabel() {
+		if label.GetName() == name {
+			return label.GetValue()
+		}
+	}
+	return ""
+}
+
+// Test that a failing module is reported separately from the modules that succeed
+func TestModuleSuccessMetrics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/server/spoolman") {
+			http.Error(w, "Spoolman not available", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"result": {"queued_jobs": [], "queue_state": "ready"}}`))
+	}))
+	defer server.Close()
+
+	c := newCollector(context.Background(), server.URL[7:], []string{"job_queue", "spoolman", "process_stats", "network_stats"}, collector.ClientConfig{})
+
+	ch := make(chan prometheus.Metric, 100)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	success := make(map[string]float64)
+	durations := make(map[string]bool)
+	up := -1.0
+	for m := range ch {
+		switch metricName(m) {
+		case "klipper_exporter_module_success":
+			success[metricLabel(t, m, "module")] = metricValue(t, m)
+		case "klipper_exporter_module_duration_seconds":
+			durations[metricLabel(t, m, "module")] = true
+		case "klipper_up":
+			up = metricValue(t, m)
+		}
+	}
+
+	expected := map[string]float64{"job_queue": 1, "spoolman": 0, "process_stats": 1, "network_stats": 1}
+	for module, value := range expected {
+		if got, ok := success[module]; !ok || got != value {
+			t.Errorf("Expected klipper_exporter_module_success{module=%q} = %v, got %v (present: %v)", module, value, got, ok)
+		}
+		if !durations[module] {
+			t.Errorf("Expected klipper_exporter_module_duration_seconds{module=%q}", module)
+		}
+	}
+	if up != 1 {
+		t.Errorf("Expected klipper_up = 1 when at least one module succeeds, got %v", up)
+	}
+}
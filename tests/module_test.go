@@ -0,0 +1,128 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+// extrasModule is an in-house module registered by code embedding the collector
+type extrasModule struct {
+	desc *prometheus.Desc
+}
+
+func (m extrasModule) Name() string         { return "test_extras" }
+func (m extrasModule) DefaultEnabled() bool { return false }
+func (m extrasModule) Endpoints() []string  { return []string{"/printer/objects/query"} }
+
+func (m extrasModule) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.desc
+}
+
+func (m extrasModule) Collect(c collector.Collector, ch chan<- prometheus.Metric) error {
+	var response struct {
+		Result struct {
+			Status struct {
+				Extras struct {
+					Value float64 `json:"value"`
+				} `json:"extras"`
+			} `json:"status"`
+		} `json:"result"`
+	}
+	if err := c.Fetch("/printer/objects/query?extras", &response); err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(m.desc, prometheus.GaugeValue, response.Result.Status.Extras.Value)
+	return nil
+}
+
+func init() {
+	collector.RegisterModule(extrasModule{
+		desc: prometheus.NewDesc("klipper_test_extras_value", "Value of the in-house extras object.", nil, nil),
+	})
+}
+
+func TestDefaultModules(t *testing.T) {
+	expected := []string{"device_power", "job_queue", "process_stats", "query_endstops", "server_info", "system_info"}
+	if got := collector.DefaultModules(); !slices.Equal(got, expected) {
+		t.Errorf("Expected default modules %v, got %v", expected, got)
+	}
+}
+
+// Test that a module registered outside the collector package is described and
+// collected like the built-in modules
+func TestRegisteredModule(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"result": {"status": {"extras": {"value": 42}}}}`))
+	}))
+	defer server.Close()
+
+	m, ok := collector.LookupModule("test_extras")
+	if !ok {
+		t.Fatal("Expected test_extras module to be registered")
+	}
+	if m.DefaultEnabled() {
+		t.Error("Expected test_extras module not to be enabled by default")
+	}
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(newCollector(context.Background(), server.URL, []string{"test_extras"}, collector.ClientConfig{}))
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+
+	values := map[string]float64{}
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			name := family.GetName()
+			for _, label := range metric.GetLabel() {
+				name += "/" + label.GetValue()
+			}
+			values[name] = metric.GetGauge().GetValue()
+		}
+	}
+	if values["klipper_test_extras_value"] != 42 {
+		t.Errorf("Expected klipper_test_extras_value = 42, got %v", values)
+	}
+	if values["klipper_exporter_module_success/test_extras"] != 1 {
+		t.Errorf("Expected klipper_exporter_module_success{module=\"test_extras\"} = 1, got %v", values)
+	}
+}
+
+func TestRegisterModuleTwicePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering a module name twice to panic")
+		}
+	}()
+	collector.RegisterModule(extrasModule{})
+}
+
+// Test that modules requesting the same endpoint share a single request per probe
+func TestModulesShareRequest(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/machine/proc_stats" {
+			requests.Add(1)
+		}
+		w.Write([]byte(`{"result": {"network": {"eth0": {"rx_bytes": 100}}}}`))
+	}))
+	defer server.Close()
+
+	c := newCollector(context.Background(), server.URL, []string{"process_stats", "network_stats"}, collector.ClientConfig{})
+	for i := 1; i <= 2; i++ {
+		ch := make(chan prometheus.Metric, 100)
+		c.Collect(ch)
+		close(ch)
+		if got := requests.Load(); got != int32(i) {
+			t.Errorf("Expected %d /machine/proc_stats requests after %d probes, got %d", i, i, got)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/scross01/prometheus-klipper-exporter/moonraker"
+)
+
+func TestMoonrakerClientServerInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/server/info" {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.Header.Get("X-API-KEY"); got != "secret" {
+			t.Errorf("Expected X-API-KEY header secret, got %q", got)
+		}
+		w.Write([]byte(`{"result": {"klippy_connected": true, "klippy_state": "ready", "components": ["history", "spoolman"], "moonraker_version": "v0.9.3", "api_version": [1, 5, 0]}}`))
+	}))
+	defer server.Close()
+
+	client := moonraker.NewClient(server.URL, moonraker.Config{APIKey: "secret"})
+	info, err := client.ServerInfo(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get server info: %v", err)
+	}
+	if !info.KlippyConnected || info.KlippyState != "ready" || info.MoonrakerVersion != "v0.9.3" {
+		t.Errorf("Unexpected server info %+v", info)
+	}
+	if !slices.Equal(info.Components, []string{"history", "spoolman"}) {
+		t.Errorf("Expected components [history spoolman], got %v", info.Components)
+	}
+}
+
+func TestMoonrakerClientStatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	_, err := moonraker.NewClient(server.URL, moonraker.Config{}).ProcStats(context.Background())
+	statusErr, ok := err.(*moonraker.StatusError)
+	if !ok || statusErr.Code != http.StatusServiceUnavailable || statusErr.Path != "/machine/proc_stats" {
+		t.Errorf("Expected a 503 StatusError for /machine/proc_stats, got %v", err)
+	}
+}
+
+// Test that the typed methods are sent through the middleware of the client
+func TestMoonrakerClientMiddleware(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Expected the middleware to answer the request, got request for %s", r.URL)
+	}))
+	defer server.Close()
+
+	var requested []string
+	client := moonraker.NewClient(server.URL, moonraker.Config{}).WithMiddleware(func(next moonraker.RequestFunc) moonraker.RequestFunc {
+		return func(ctx context.Context, method, urlPath string, body []byte) ([]byte, error) {
+			requested = append(requested, method+" "+urlPath)
+			return []byte(`{"result": {"printer": "on", "light strip": "off"}}`), nil
+		}
+	})
+
+	if _, err := client.PowerDeviceStatus(context.Background(), "printer", "light strip"); err != nil {
+		t.Fatalf("Failed to get power device status: %v", err)
+	}
+	expected := []string{"GET /machine/device_power/status?device=printer&device=light+strip"}
+	if !slices.Equal(requested, expected) {
+		t.Errorf("Expected requests %v, got %v", expected, requested)
+	}
+}
+
+func TestMoonrakerQueryPrinterObjects(t *testing.T) {
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write([]byte(`{"result": {"eventtime": 1.5, "status": {"temperature_sensor chamber": {"temperature": 31.5}, "toolhead": {"homed_axes": "xyz"}}}}`))
+	}))
+	defer server.Close()
+
+	var status struct {
+		Chamber struct {
+			Temperature float64 `json:"temperature"`
+		} `json:"temperature_sensor chamber"`
+		Toolhead struct {
+			HomedAxes string `json:"homed_axes"`
+		} `json:"toolhead"`
+	}
+	objects := moonraker.Objects{
+		"toolhead":                   {"homed_axes", "stalls"},
+		"temperature_sensor chamber": nil,
+	}
+	client := moonraker.NewClient(server.URL, moonraker.Config{})
+	if err := client.QueryPrinterObjects(context.Background(), objects, &status); err != nil {
+		t.Fatalf("Failed to query printer objects: %v", err)
+	}
+
+	if expected := "temperature_sensor%20chamber&toolhead=homed_axes,stalls"; query != expected {
+		t.Errorf("Expected query %s, got %s", expected, query)
+	}
+	if status.Chamber.Temperature != 31.5 || status.Toolhead.HomedAxes != "xyz" {
+		t.Errorf("Unexpected printer object status %+v", status)
+	}
+}
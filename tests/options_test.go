@@ -0,0 +1,206 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+	log "github.com/sirupsen/logrus"
+)
+
+// testState is shared by the test collectors, like the exporter state shared
+// between probes
+var testState = collector.NewState()
+
+// newCollector returns a collector using the shared test state
+func newCollector(ctx context.Context, target string, modules []string, config collector.ClientConfig) *collector.Collector {
+	return collector.New(target,
+		collector.WithContext(ctx),
+		collector.WithModules(modules...),
+		collector.WithClientConfig(config),
+		collector.WithState(testState),
+	)
+}
+
+func TestWithConstLabels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(jobQueueFixture))
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(collector.New(server.URL,
+		collector.WithModules("job_queue"),
+		collector.WithConstLabels(prometheus.Labels{"printer": "voron"}),
+	))
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	if len(families) == 0 {
+		t.Fatal("Expected metrics, got none")
+	}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			labeled := false
+			for _, label := range m.GetLabel() {
+				labeled = labeled || label.GetName() == "printer" && label.GetValue() == "voron"
+			}
+			if !labeled {
+				t.Errorf("Expected %s to have the label printer=voron, got %v", family.GetName(), m.GetLabel())
+			}
+		}
+	}
+}
+
+// roundTripFunc is an http.RoundTripper calling the function
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(jobQueueFixture))
+	}))
+	defer server.Close()
+
+	var requests atomic.Int32
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		requests.Add(1)
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+
+	c := collector.New(server.URL, collector.WithModules("job_queue"), collector.WithHTTPClient(client))
+	if success := collectModuleSuccess(t, c); success["job_queue"] != 1 {
+		t.Errorf("Expected job_queue to succeed, got %v", success)
+	}
+	if requests.Load() != 1 {
+		t.Errorf("Expected 1 request through the HTTP client, got %d", requests.Load())
+	}
+}
+
+func TestWithLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(jobQueueFixture))
+	}))
+	defer server.Close()
+
+	var output bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&output)
+	logger.SetLevel(log.InfoLevel)
+
+	collectModuleSuccess(t, collector.New(server.URL, collector.WithModules("job_queue", "unknown"), collector.WithLogger(logger)))
+	for _, expected := range []string{"Collecting job_queue for " + server.URL, "Unknown module unknown for " + server.URL} {
+		if !strings.Contains(output.String(), expected) {
+			t.Errorf("Expected the logger output to contain %q, got %q", expected, output.String())
+		}
+	}
+}
+
+// Test that nothing is logged to the standard logger unless a logger is set
+func TestDefaultLogger(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(jobQueueFixture))
+	}))
+	defer server.Close()
+
+	var output bytes.Buffer
+	defer log.SetOutput(log.StandardLogger().Out)
+	defer log.SetLevel(log.GetLevel())
+	log.SetOutput(&output)
+	log.SetLevel(log.DebugLevel)
+
+	collectModuleSuccess(t, collector.New(server.URL, collector.WithModules("job_queue", "unknown"), collector.WithState(collector.NewState())))
+	if output.Len() != 0 {
+		t.Errorf("Expected no standard logger output, got %q", output.String())
+	}
+}
+
+// Test that the requests of a probe are logged with the logger of the probe,
+// when the Moonraker client is shared with an earlier probe
+func TestWithLoggerSharedClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(jobQueueFixture))
+	}))
+	defer server.Close()
+
+	state := collector.NewState()
+	var outputs [2]bytes.Buffer
+	for i := range outputs {
+		logger := log.New()
+		logger.SetOutput(&outputs[i])
+		logger.SetLevel(log.DebugLevel)
+		collectModuleSuccess(t, collector.New(server.URL, collector.WithModules("job_queue"), collector.WithState(state), collector.WithLogger(logger)))
+	}
+	for i := range outputs {
+		if !strings.Contains(outputs[i].String(), "Requesting GET "+server.URL+"/server/job_queue/status") {
+			t.Errorf("Expected the request in the output of logger %d, got %q", i, outputs[i].String())
+		}
+	}
+}
+
+func TestWithLabelSanitizer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/machine/device_power/devices":
+			w.Write([]byte(`{"result": {"devices": [{"device": "light strip", "type": "gpio"}]}}`))
+		default:
+			w.Write([]byte(`{"result": {"light strip": "on"}}`))
+		}
+	}))
+	defer server.Close()
+
+	c := collector.New(server.URL,
+		collector.WithModules("device_power"),
+		collector.WithLabelSanitizer(strings.ToUpper),
+	)
+	ch := make(chan prometheus.Metric, 100)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	devices := map[string]bool{}
+	for m := range ch {
+		if metricName(m) == "klipper_power_device_status" {
+			devices[metricLabel(t, m, "device")] = true
+		}
+	}
+	if !devices["LIGHT STRIP"] || len(devices) != 1 {
+		t.Errorf("Expected the device label LIGHT STRIP, got %v", devices)
+	}
+}
+
+// Test that collectors created without a shared state do not share responses
+func TestStateNotSharedByDefault(t *testing.T) {
+	var requests atomic.Int32
+	server := newCountingServer(0, &requests)
+	defer server.Close()
+
+	config := collector.ClientConfig{CacheTTL: time.Minute}
+	for i := 0; i < 2; i++ {
+		c := collector.New(server.URL, collector.WithModules("job_queue"), collector.WithClientConfig(config))
+		collectModuleSuccess(t, c)
+	}
+	if requests.Load() != 2 {
+		t.Errorf("Expected 2 requests for collectors with their own state, got %d", requests.Load())
+	}
+
+	state := collector.NewState()
+	for i := 0; i < 2; i++ {
+		c := collector.New(server.URL, collector.WithModules("job_queue"), collector.WithClientConfig(config), collector.WithState(state))
+		collectModuleSuccess(t, c)
+	}
+	if requests.Load() != 3 {
+		t.Errorf("Expected 1 more request for collectors with a shared state, got %d", requests.Load()-2)
+	}
+}
@@ -0,0 +1,78 @@
+package test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/scross01/prometheus-klipper-exporter/config"
+)
+
+func TestReloadConfig(t *testing.T) {
+	filename := writeConfig(t, "targets:\n  voron24:\n    address: 192.168.1.10:7125\n")
+	registry := prometheus.NewRegistry()
+	safeConfig := config.NewSafeConfig(filename, registry)
+
+	if err := safeConfig.Reload(); err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	initial := safeConfig.Get()
+	if _, ok := initial.Target("voron24"); !ok {
+		t.Fatal("Expected voron24 target after initial load")
+	}
+
+	// an invalid config is rejected and the current config is kept
+	if err := os.WriteFile(filename, []byte("targets:\n  ender3:\n    api_key: abc\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	if err := safeConfig.Reload(); err == nil {
+		t.Fatal("Expected reload of invalid config to fail")
+	}
+	if safeConfig.Get() != initial {
+		t.Error("Expected current config to be kept after failed reload")
+	}
+	expectReloadMetrics(t, registry, 0)
+
+	// a valid config replaces the current config
+	if err := os.WriteFile(filename, []byte("targets:\n  ender3:\n    address: 192.168.1.11:7125\n"), 0600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	if err := safeConfig.Reload(); err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	if _, ok := safeConfig.Get().Target("ender3"); !ok {
+		t.Error("Expected ender3 target after reload")
+	}
+	if _, ok := safeConfig.Get().Target("voron24"); ok {
+		t.Error("Expected voron24 target to be removed after reload")
+	}
+	if _, ok := initial.Target("voron24"); !ok {
+		t.Error("Expected the previous config to be unchanged for in-flight probes")
+	}
+	expectReloadMetrics(t, registry, 1)
+}
+
+func expectReloadMetrics(t *testing.T, registry *prometheus.Registry, success float64) {
+	t.Helper()
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		value := family.GetMetric()[0].GetGauge().GetValue()
+		switch family.GetName() {
+		case "klipper_exporter_config_last_reload_successful":
+			if value != success {
+				t.Errorf("Expected last reload successful %v, got %v", success, value)
+			}
+		case "klipper_exporter_config_last_reload_success_timestamp_seconds":
+			if value == 0 {
+				t.Error("Expected last successful reload timestamp to be set")
+			}
+		}
+	}
+	if n, err := testutil.GatherAndCount(registry); err != nil || n != 2 {
+		t.Errorf("Expected 2 reload metrics, got %d: %v", n, err)
+	}
+}
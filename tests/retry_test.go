@@ -0,0 +1,85 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+// newFlakyServer returns 503 for the first failures requests, then the job queue
+func newFlakyServer(failures int32, status int, requests *atomic.Int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		w.Write([]byte(jobQueueFixture))
+	}))
+}
+
+func TestRetryFailedRequests(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		retries  int
+		success  float64
+		requests int32
+	}{
+		{name: "retried until success", status: http.StatusServiceUnavailable, retries: 2, success: 1, requests: 3},
+		{name: "retries exhausted", status: http.StatusServiceUnavailable, retries: 1, success: 0, requests: 2},
+		{name: "retries disabled", status: http.StatusServiceUnavailable, retries: 0, success: 0, requests: 1},
+		{name: "client errors not retried", status: http.StatusNotFound, retries: 2, success: 0, requests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := newFlakyServer(2, tt.status, &requests)
+			defer server.Close()
+
+			c := newCollector(context.Background(), server.URL, []string{"job_queue"}, collector.ClientConfig{Retries: tt.retries})
+			if success := collectModuleSuccess(t, c); success["job_queue"] != tt.success {
+				t.Errorf("Expected job_queue success %v, got %v", tt.success, success["job_queue"])
+			}
+			if n := requests.Load(); n != tt.requests {
+				t.Errorf("Expected %d requests, got %d", tt.requests, n)
+			}
+		})
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var requests atomic.Int32
+	server := newFlakyServer(2, http.StatusServiceUnavailable, &requests)
+	defer server.Close()
+
+	config := collector.ClientConfig{CircuitBreaker: collector.CircuitBreakerConfig{Failures: 2, Cooldown: 100 * time.Millisecond}}
+	probe := func() float64 {
+		c := newCollector(context.Background(), server.URL, []string{"job_queue"}, config)
+		return collectModuleSuccess(t, c)["job_queue"]
+	}
+
+	probe()
+	probe()
+	if state := gatheredValue(t, "klipper_exporter_target_circuit_state", "target", server.URL); state != 2 {
+		t.Fatalf("Expected circuit to be open (2) after consecutive failures, got %v", state)
+	}
+
+	// requests fast-fail while the circuit is open
+	if success := probe(); success != 0 || requests.Load() != 2 {
+		t.Errorf("Expected fast-fail without a request, got success %v after %d requests", success, requests.Load())
+	}
+
+	// a successful trial request after the cooldown closes the circuit
+	time.Sleep(150 * time.Millisecond)
+	if success := probe(); success != 1 {
+		t.Errorf("Expected job_queue success after cooldown, got %v", success)
+	}
+	if state := gatheredValue(t, "klipper_exporter_target_circuit_state", "target", server.URL); state != 0 {
+		t.Errorf("Expected circuit to be closed (0) after successful trial, got %v", state)
+	}
+}
@@ -0,0 +1,87 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+// newReverseProxyServer simulates Moonraker published under /printer3 behind a
+// proxy that requires HTTP Basic auth and a custom header.
+func newReverseProxyServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "farm" || password != "s3cret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if r.Header.Get("X-Farm-Token") != "abc" {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/printer3/server/job_queue/status":
+			w.Write([]byte(jobQueueFixture))
+		case "/printer3/server/spoolman/status":
+			w.Write([]byte(`{"result": {"spoolman_connected": true, "pending_reports": [], "spool_id": null}}`))
+		case "/printer3/server/spoolman/proxy":
+			w.Write([]byte(`{"result": {"response": [], "error": null}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestReverseProxyTarget(t *testing.T) {
+	server := newReverseProxyServer(t)
+	defer server.Close()
+
+	headers := map[string]string{"X-Farm-Token": "abc"}
+	tests := []struct {
+		name    string
+		target  string
+		config  collector.ClientConfig
+		success float64
+	}{
+		{
+			name:    "base path, headers and basic auth",
+			target:  server.URL,
+			config:  collector.ClientConfig{BasePath: "/printer3/", Headers: headers, BasicAuth: &collector.BasicAuth{Username: "farm", Password: "s3cret"}},
+			success: 1,
+		},
+		{
+			name:    "path and credentials in target URL",
+			target:  "http://farm:s3cret@" + server.URL[7:] + "/printer3/",
+			config:  collector.ClientConfig{Headers: headers},
+			success: 1,
+		},
+		{
+			name:    "missing basic auth",
+			target:  server.URL,
+			config:  collector.ClientConfig{BasePath: "printer3", Headers: headers},
+			success: 0,
+		},
+		{
+			name:    "missing base path",
+			target:  server.URL,
+			config:  collector.ClientConfig{Headers: headers, BasicAuth: &collector.BasicAuth{Username: "farm", Password: "s3cret"}},
+			success: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCollector(context.Background(), tt.target, []string{"job_queue", "spoolman"}, tt.config)
+			success := collectModuleSuccess(t, c)
+			for _, module := range []string{"job_queue", "spoolman"} {
+				if success[module] != tt.success {
+					t.Errorf("Expected %s success %v, got %v", module, tt.success, success[module])
+				}
+			}
+		})
+	}
+}
@@ -124,7 +124,7 @@ func TestSpoolmanNoSpools(t *testing.T) {
 	server := httptest.NewServer(testSpoolmanHandler(statusFixture, proxyFixture))
 	defer server.Close()
 
-	c := collector.New(context.Background(), server.URL[7:], []string{"spoolman"}, "")
+	c := newCollector(context.Background(), server.URL[7:], []string{"spoolman"}, collector.ClientConfig{})
 
 	ch := make(chan prometheus.Metric, 100)
 	go func() {
@@ -177,7 +177,7 @@ func TestSpoolmanDisconnected(t *testing.T) {
 	server := httptest.NewServer(testSpoolmanHandler(statusFixture, proxyFixture))
 	defer server.Close()
 
-	c := collector.New(context.Background(), server.URL[7:], []string{"spoolman"}, "")
+	c := newCollector(context.Background(), server.URL[7:], []string{"spoolman"}, collector.ClientConfig{})
 
 	ch := make(chan prometheus.Metric, 100)
 	go func() {
@@ -234,7 +234,7 @@ func TestSpoolmanProxyError(t *testing.T) {
 	server := httptest.NewServer(testSpoolmanHandler(statusFixture, proxyFixture))
 	defer server.Close()
 
-	c := collector.New(context.Background(), server.URL[7:], []string{"spoolman"}, "")
+	c := newCollector(context.Background(), server.URL[7:], []string{"spoolman"}, collector.ClientConfig{})
 
 	ch := make(chan prometheus.Metric, 100)
 	go func() {
@@ -278,7 +278,7 @@ func TestSpoolmanProxyError(t *testing.T) {
 func assertSpoolmanMetrics(t *testing.T, server *httptest.Server) {
 	t.Helper()
 
-	c := collector.New(context.Background(), server.URL[7:], []string{"spoolman"}, "")
+	c := newCollector(context.Background(), server.URL[7:], []string{"spoolman"}, collector.ClientConfig{})
 
 	ch := make(chan prometheus.Metric, 100)
 	go func() {
@@ -0,0 +1,184 @@
+package test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+// subscriptionServer simulates the Moonraker websocket. Each connection is
+// answered with the initial extruder status, after which status updates sent
+// on the updates channel are pushed as `notify_status_update` notifications.
+type subscriptionServer struct {
+	updates     chan float64
+	disconnect  chan struct{}
+	connections chan struct{}
+	queries     int
+}
+
+func (s *subscriptionServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/printer/objects/list":
+		w.Write([]byte(`{"result": {"objects": ["extruder", "heater_bed"]}}`))
+	case "/printer/objects/query":
+		s.queries++
+		w.Write([]byte(`{"result": {"status": {"extruder": {"temperature": 1.0}}}}`))
+	case "/websocket":
+		upgrader := websocket.Upgrader{}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s.connections <- struct{}{}
+
+		requests := make(chan map[string]interface{})
+		go func() {
+			defer close(requests)
+			for {
+				var request map[string]interface{}
+				if err := conn.ReadJSON(&request); err != nil {
+					return
+				}
+				requests <- request
+			}
+		}()
+		for {
+			select {
+			case request, ok := <-requests:
+				if !ok {
+					return
+				}
+				result := `{"connection_id": 1}`
+				if request["method"] == "printer.objects.subscribe" {
+					result = `{"eventtime": 1.0, "status": {"extruder": {"temperature": 200.0, "target": 210.0}}}`
+				}
+				id, _ := json.Marshal(request["id"])
+				conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc": "2.0", "result": `+result+`, "id": `+string(id)+`}`))
+			case temperature := <-s.updates:
+				conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"jsonrpc": "2.0", "method": "notify_status_update", "params": [{"extruder": {"temperature": %v}}, 2.0]}`, temperature)))
+			case <-s.disconnect:
+				return
+			}
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// collectGauges runs a collection and returns the unlabelled metric values by name
+func collectGauges(t *testing.T, c *collector.Collector) map[string]float64 {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 1000)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	values := make(map[string]float64)
+	for m := range ch {
+		values[metricName(m)] = metricValue(t, m)
+	}
+	return values
+}
+
+func TestSubscriptionMode(t *testing.T) {
+	moonraker := &subscriptionServer{
+		updates:     make(chan float64),
+		disconnect:  make(chan struct{}),
+		connections: make(chan struct{}, 10),
+	}
+	server := httptest.NewServer(moonraker)
+	defer server.Close()
+
+	config := collector.ClientConfig{Subscribe: true}
+	probe := func() map[string]float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return collectGauges(t, newCollector(ctx, server.URL, []string{"printer_objects"}, config))
+	}
+
+	values := probe()
+	if values["klipper_extruder_temperature"] != 200 {
+		t.Fatalf("Expected initial subscribed extruder temperature 200, got %v", values["klipper_extruder_temperature"])
+	}
+	if values["klipper_exporter_subscription_connected"] != 1 {
+		t.Errorf("Expected subscription to be connected, got %v", values["klipper_exporter_subscription_connected"])
+	}
+	if moonraker.queries != 0 {
+		t.Errorf("Expected no printer object queries in subscription mode, got %d", moonraker.queries)
+	}
+
+	// status updates are merged into the subscribed status
+	moonraker.updates <- 215.5
+	waitFor(t, func() bool { return probe()["klipper_extruder_temperature"] == 215.5 })
+	if values := probe(); values["klipper_extruder_target"] != 210 {
+		t.Errorf("Expected unchanged extruder target 210 after update, got %v", values["klipper_extruder_target"])
+	}
+
+	// the subscription reconnects after the connection is lost
+	moonraker.disconnect <- struct{}{}
+	<-moonraker.connections
+	select {
+	case <-moonraker.connections:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for subscription to reconnect")
+	}
+	waitFor(t, func() bool { return probe()["klipper_exporter_subscription_reconnects_total"] == 1 })
+}
+
+// waitFor polls the condition until it is true or the test times out
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Test that a probe without the API key doesn't get the status of the
+// subscription of a probe with the API key
+func TestSubscriptionPerCredentials(t *testing.T) {
+	moonraker := &subscriptionServer{
+		updates:     make(chan float64),
+		disconnect:  make(chan struct{}),
+		connections: make(chan struct{}, 10),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "secret" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		moonraker.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	state := collector.NewState()
+	probe := func(apiKey string) map[string]float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return collectGauges(t, collector.New(server.URL,
+			collector.WithContext(ctx),
+			collector.WithModules("printer_objects"),
+			collector.WithClientConfig(collector.ClientConfig{APIKey: apiKey, Subscribe: true}),
+			collector.WithState(state),
+		))
+	}
+
+	if values := probe("secret"); values["klipper_extruder_temperature"] != 200 {
+		t.Fatalf("Expected subscribed extruder temperature 200 with the API key, got %v", values["klipper_extruder_temperature"])
+	}
+	if values := probe(""); values["klipper_extruder_temperature"] != 0 || values["klipper_exporter_subscription_connected"] != 0 {
+		t.Errorf("Expected no subscribed status without the API key, got %v", values)
+	}
+}
@@ -47,7 +47,7 @@ func TestSystemInfoMetrics(t *testing.T) {
 	}))
 	defer server.Close()
 
-	c := collector.New(context.Background(), server.URL[7:], []string{"system_info"}, "")
+	c := newCollector(context.Background(), server.URL[7:], []string{"system_info"}, collector.ClientConfig{})
 
 	ch := make(chan prometheus.Metric, 100)
 	go func() {
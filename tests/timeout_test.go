@@ -0,0 +1,52 @@
+package test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+// Test that a hung Moonraker does not hold the probe beyond the context deadline
+func TestCollectHonorsContextDeadline(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	c := newCollector(ctx, server.URL[7:], []string{"server_info", "job_queue", "system_info"}, collector.ClientConfig{})
+
+	ch := make(chan prometheus.Metric, 100)
+	start := time.Now()
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	for m := range ch {
+		name := metricName(m)
+		if !strings.HasPrefix(name, "klipper_exporter_module_") && name != "klipper_up" {
+			t.Errorf("Expected no module metrics from a hung target, got %s", name)
+		}
+		if (name == "klipper_exporter_module_success" || name == "klipper_up") && metricValue(t, m) != 0 {
+			t.Errorf("Expected %s to be 0 for a hung target", name)
+		}
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Collect took %s, expected it to stop at the context deadline", elapsed)
+	}
+}
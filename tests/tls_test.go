@@ -0,0 +1,153 @@
+package test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+const jobQueueFixture = `{"result": {"queued_jobs": [], "queue_state": "ready"}}`
+
+// collectModuleSuccess runs a collection and returns the klipper_exporter_module_success values
+func collectModuleSuccess(t *testing.T, c *collector.Collector) map[string]float64 {
+	t.Helper()
+	ch := make(chan prometheus.Metric, 100)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+	success := make(map[string]float64)
+	for m := range ch {
+		if metricName(m) == "klipper_exporter_module_success" {
+			success[metricLabel(t, m, "module")] = metricValue(t, m)
+		}
+	}
+	return success
+}
+
+func TestHTTPSTarget(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(jobQueueFixture))
+	}))
+	defer server.Close()
+
+	// Write the test server certificate as the CA bundle
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatalf("Failed to write CA file: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		tls     collector.TLSConfig
+		success float64
+	}{
+		{
+			name:    "unknown certificate authority",
+			tls:     collector.TLSConfig{},
+			success: 0,
+		},
+		{
+			name:    "custom CA bundle",
+			tls:     collector.TLSConfig{CAFile: caFile},
+			success: 1,
+		},
+		{
+			name:    "server name override",
+			tls:     collector.TLSConfig{CAFile: caFile, ServerName: "example.com"},
+			success: 1,
+		},
+		{
+			name:    "server name mismatch",
+			tls:     collector.TLSConfig{CAFile: caFile, ServerName: "klipper.invalid"},
+			success: 0,
+		},
+		{
+			name:    "insecure skip verify",
+			tls:     collector.TLSConfig{InsecureSkipVerify: true},
+			success: 1,
+		},
+		{
+			name:    "client certificate without key",
+			tls:     collector.TLSConfig{CAFile: caFile, CertFile: caFile},
+			success: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCollector(context.Background(), server.URL, []string{"job_queue"}, collector.ClientConfig{TLS: tt.tls})
+			success := collectModuleSuccess(t, c)
+			if success["job_queue"] != tt.success {
+				t.Errorf("Expected job_queue success %v, got %v", tt.success, success["job_queue"])
+			}
+		})
+	}
+}
+
+// Test that the CA bundle is read again when the file changes
+func TestHTTPSTargetCAFileChanged(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(jobQueueFixture))
+	}))
+	defer server.Close()
+
+	// Start with a CA bundle of an unrelated certificate authority
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Other CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	otherCA, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: otherCA}), 0600); err != nil {
+		t.Fatalf("Failed to write CA file: %v", err)
+	}
+
+	state := collector.NewState()
+	probe := func() float64 {
+		c := collector.New(server.URL, collector.WithModules("job_queue"), collector.WithState(state),
+			collector.WithClientConfig(collector.ClientConfig{TLS: collector.TLSConfig{CAFile: caFile}}))
+		return collectModuleSuccess(t, c)["job_queue"]
+	}
+
+	if success := probe(); success != 0 {
+		t.Errorf("Expected job_queue success 0 with the other CA, got %v", success)
+	}
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600); err != nil {
+		t.Fatalf("Failed to write CA file: %v", err)
+	}
+	modTime := time.Now().Add(time.Minute)
+	if err := os.Chtimes(caFile, modTime, modTime); err != nil {
+		t.Fatalf("Failed to change CA file time: %v", err)
+	}
+	if success := probe(); success != 1 {
+		t.Errorf("Expected job_queue success 1 after the CA file changed, got %v", success)
+	}
+}
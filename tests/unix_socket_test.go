@@ -0,0 +1,89 @@
+package test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/scross01/prometheus-klipper-exporter/collector"
+)
+
+// serveMoonrakerSocket simulates the Moonraker JSON-RPC unix socket, answering
+// each request from the results map keyed by method. A notification is sent
+// ahead of every response to verify unrelated messages are skipped.
+func serveMoonrakerSocket(t *testing.T, listener net.Listener, results map[string]string, requests chan<- map[string]interface{}) {
+	t.Helper()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			for {
+				message, err := reader.ReadBytes(0x03)
+				if err != nil {
+					return
+				}
+				var request map[string]interface{}
+				if err := json.Unmarshal(message[:len(message)-1], &request); err != nil {
+					return
+				}
+				requests <- request
+				conn.Write(append([]byte(`{"jsonrpc": "2.0", "method": "notify_proc_stat_update", "params": [{}]}`), 0x03))
+				id, _ := json.Marshal(request["id"])
+				response := `{"jsonrpc": "2.0", "error": {"code": 404, "message": "Method not found"}, "id": ` + string(id) + `}`
+				if result, ok := results[request["method"].(string)]; ok {
+					response = `{"jsonrpc": "2.0", "result": ` + result + `, "id": ` + string(id) + `}`
+				}
+				conn.Write(append([]byte(response), 0x03))
+			}
+		}(conn)
+	}
+}
+
+func TestUnixSocketTarget(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "moonraker.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to listen on unix socket: %v", err)
+	}
+	defer listener.Close()
+
+	results := map[string]string{
+		"server.job_queue.status": `{"queued_jobs": [], "queue_state": "ready"}`,
+		"server.history.list":     `{"count": 0, "jobs": []}`,
+		"server.history.totals":   `{"job_totals": {"total_jobs": 3, "total_time": 10, "total_print_time": 8, "total_filament_used": 100, "longest_job": 5, "longest_print": 4}}`,
+	}
+	requests := make(chan map[string]interface{}, 100)
+	go serveMoonrakerSocket(t, listener, results, requests)
+
+	c := newCollector(context.Background(), "unix://"+socketPath, []string{"job_queue", "history", "system_info"}, collector.ClientConfig{})
+	success := collectModuleSuccess(t, c)
+	close(requests)
+
+	if success["job_queue"] != 1 {
+		t.Errorf("Expected job_queue success over unix socket, got %v", success["job_queue"])
+	}
+	if success["history"] != 1 {
+		t.Errorf("Expected history success over unix socket, got %v", success["history"])
+	}
+	if success["system_info"] != 0 {
+		t.Errorf("Expected system_info failure for JSON-RPC error, got %v", success["system_info"])
+	}
+
+	// Query string arguments are converted to typed JSON-RPC params
+	for request := range requests {
+		if request["method"] != "server.history.list" {
+			continue
+		}
+		params, _ := request["params"].(map[string]interface{})
+		if params["limit"] != float64(1) {
+			t.Errorf("Expected numeric limit param, got %#v", params["limit"])
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package test
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// startExporter starts the exporter with the arguments on a free port, and
+// returns its URL once it accepts connections
+func startExporter(t *testing.T, args ...string) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	cmd := exec.Command(exporterBinary, append([]string{"-web.listen-address", address}, args...)...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start the exporter: %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if conn, err := net.Dial("tcp", address); err == nil {
+			conn.Close()
+			return "http://" + address
+		}
+	}
+	t.Fatalf("Exporter did not start listening on %s", address)
+	return ""
+}
+
+// Test that /probe and /metrics require the basic auth credentials of the web
+// configuration file
+func TestWebConfigBasicAuth(t *testing.T) {
+	server := newJobQueueServer()
+	defer server.Close()
+
+	webConfig := filepath.Join(t.TempDir(), "web-config.yml")
+	// bcrypt hash of the password `changeme`
+	contents := "basic_auth_users:\n  prometheus: $2a$10$8zkuzDfCowhvU5uGyIwfh.ApKlKlOQQIU56Uj2wB7RvXxJxoNyIqy\n"
+	if err := os.WriteFile(webConfig, []byte(contents), 0600); err != nil {
+		t.Fatalf("Failed to write web config: %v", err)
+	}
+	exporter := startExporter(t, "-web.config.file", webConfig, "-logging.level", "error")
+
+	for name, path := range map[string]string{"probe": "/probe?modules=job_queue&target=" + server.URL, "metrics": "/metrics"} {
+		t.Run(name, func(t *testing.T) {
+			tests := []struct {
+				name     string
+				username string
+				password string
+				status   int
+			}{
+				{name: "unauthenticated", status: http.StatusUnauthorized},
+				{name: "wrong password", username: "prometheus", password: "wrong", status: http.StatusUnauthorized},
+				{name: "authenticated", username: "prometheus", password: "changeme", status: http.StatusOK},
+			}
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					req, err := http.NewRequest(http.MethodGet, exporter+path, nil)
+					if err != nil {
+						t.Fatalf("Failed to create request: %v", err)
+					}
+					if tt.username != "" {
+						req.SetBasicAuth(tt.username, tt.password)
+					}
+					res, err := http.DefaultClient.Do(req)
+					if err != nil {
+						t.Fatalf("Failed to request %s: %v", path, err)
+					}
+					res.Body.Close()
+					if res.StatusCode != tt.status {
+						t.Errorf("Expected status %d, got %d", tt.status, res.StatusCode)
+					}
+				})
+			}
+		})
+	}
+}